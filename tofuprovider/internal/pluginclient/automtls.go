@@ -0,0 +1,329 @@
+package pluginclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// AutoMTLSConfig describes how to launch a plugin using [StartAutoMTLS].
+type AutoMTLSConfig struct {
+	// Cmd is the command to run to launch the plugin. The Stdout and Stderr
+	// fields must not be set, because StartAutoMTLS sets them itself. If the
+	// WaitDelay field is not set then StartAutoMTLS sets it to a short delay.
+	Cmd *exec.Cmd
+
+	// CookieKey and CookieValue are the "magic cookie" environment variable
+	// that the plugin expects to be set as part of the handshake.
+	CookieKey, CookieValue string
+
+	// ProtoVersions are the protocol major versions to offer to the plugin
+	// during negotiation.
+	ProtoVersions []int
+
+	// Stderr is where the plugin's stderr stream is written.
+	Stderr *StderrTail
+}
+
+// StartAutoMTLS launches a plugin child process using the same handshake as
+// the rpcplugin library, but with the additional steps required to use
+// mutually-authenticated TLS for the connection to the plugin, compatible
+// with the "AutoMTLS" mechanism from HashiCorp's go-plugin library.
+//
+// This generates an ephemeral client certificate, passes it to the plugin
+// in the PLUGIN_CLIENT_CERT environment variable, and then connects using
+// only the server certificate that the plugin returns in its handshake
+// response. The plugin must support this mechanism, or launching fails.
+//
+// If successful, returns the plugin, the negotiated protocol major version,
+// and the connection to use to make requests to the plugin.
+func StartAutoMTLS(ctx context.Context, config *AutoMTLSConfig) (*Plugin, int, *grpc.ClientConn, error) {
+	clientCert, clientCertPEM, err := generateCert()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to generate client certificate: %w", err)
+	}
+
+	cmd := config.Cmd
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	versionStrs := make([]string, len(config.ProtoVersions))
+	for i, v := range config.ProtoVersions {
+		versionStrs[i] = strconv.Itoa(v)
+	}
+	cmd.Env = append(slices.Clip(env),
+		config.CookieKey+"="+config.CookieValue,
+		"PLUGIN_PROTOCOL_VERSIONS="+strings.Join(versionStrs, ","),
+		"PLUGIN_CLIENT_CERT="+string(clientCertPEM),
+	)
+	cmd.Stderr = config.Stderr
+	if cmd.WaitDelay == 0 {
+		// Waiting for the process also waits for its stderr stream to be
+		// closed, which won't happen if the plugin started a subprocess
+		// that inherited its stderr and is still running. Closing the
+		// plugin waits for the process, so we need to bound that wait.
+		cmd.WaitDelay = autoMTLSShutdownTimeout
+	}
+
+	// We use our own pipe for stdout, rather than [exec.Cmd.StdoutPipe],
+	// so that we can keep reading it for as long as the plugin writes to
+	// it without racing with our call to [exec.Cmd.Wait].
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cmd.Stdout = stdoutW
+	err = cmd.Start()
+	stdoutW.Close() // the child process has its own copy now, if it started
+	if err != nil {
+		stdoutR.Close()
+		return nil, 0, nil, err
+	}
+
+	lineCh := make(chan string, 1)
+	go func() {
+		defer stdoutR.Close()
+		sc := bufio.NewScanner(stdoutR)
+		if sc.Scan() {
+			lineCh <- sc.Text()
+		}
+		close(lineCh)
+		// The handshake only uses the first line, but we must keep
+		// reading so that the plugin doesn't block if it writes more.
+		_, _ = io.Copy(io.Discard, stdoutR)
+	}()
+
+	// We need to be able to terminate the child process if anything goes
+	// wrong before we return, so we'll create the Plugin object early and
+	// close it on failure. Its closer will be populated once we have a
+	// connection.
+	closer := &autoMTLSCloser{}
+	plugin := newOwnedChildProcess(closer, cmd, config.Stderr)
+	closer.plugin = plugin
+
+	var line string
+	select {
+	case l, ok := <-lineCh:
+		if !ok {
+			plugin.Close()
+			return nil, 0, nil, fmt.Errorf("plugin exited before completing the handshake")
+		}
+		line = l
+	case <-ctx.Done():
+		plugin.Close()
+		return nil, 0, nil, ctx.Err()
+	}
+
+	handshake, err := parseHandshake(line, config.ProtoVersions)
+	if err != nil {
+		plugin.Close()
+		return nil, 0, nil, err
+	}
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(handshake.serverCert)
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      rootCAs,
+		ServerName:   "localhost",
+		MinVersion:   tls.VersionTLS12,
+	})
+	conn, err := Dial(handshake.addr, creds)
+	if err != nil {
+		plugin.Close()
+		return nil, 0, nil, err
+	}
+	closer.conn = conn
+	return plugin, handshake.protoVersion, conn, nil
+}
+
+// autoMTLSCloser is the [io.Closer] used for plugins launched by
+// [StartAutoMTLS].
+type autoMTLSCloser struct {
+	plugin *Plugin
+	conn   *grpc.ClientConn // nil if we didn't get as far as connecting
+}
+
+// autoMTLSShutdownTimeout is how long we'll wait for a plugin launched by
+// [StartAutoMTLS] to exit after asking it to shut down, before we kill it.
+const autoMTLSShutdownTimeout = 2 * time.Second
+
+func (c *autoMTLSCloser) Close() error {
+	if c.conn != nil {
+		// Plugins built with go-plugin offer a "controller" service that
+		// allows the client to ask the plugin to shut itself down.
+		ctx, cancel := context.WithTimeout(context.Background(), autoMTLSShutdownTimeout)
+		_ = c.conn.Invoke(ctx, "/plugin.GRPCController/Shutdown", &emptypb.Empty{}, &emptypb.Empty{})
+		cancel()
+		c.conn.Close()
+	}
+
+	timer := time.NewTimer(autoMTLSShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.plugin.exited:
+		return nil
+	case <-timer.C:
+	}
+	err := c.plugin.process.Kill()
+	<-c.plugin.exited
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+// ProtocolVersionError is the error returned when a plugin completes its
+// handshake by selecting a protocol version that the client did not offer,
+// which typically means that the plugin doesn't support any of the offered
+// versions and has fallen back to its default.
+type ProtocolVersionError struct {
+	// Selected is the protocol version that the plugin selected.
+	Selected int
+
+	// Offered are the protocol versions that the client offered.
+	Offered []int
+
+	// Err is the error that reported the mismatch, if it was detected by
+	// some other component such as rpcplugin, or nil otherwise.
+	Err error
+}
+
+func (e ProtocolVersionError) Error() string {
+	strs := make([]string, len(e.Offered))
+	for i, v := range e.Offered {
+		strs[i] = strconv.Itoa(v)
+	}
+	return fmt.Sprintf("plugin selected protocol version %d, but the client offered only %s", e.Selected, strings.Join(strs, ", "))
+}
+
+func (e ProtocolVersionError) Unwrap() error {
+	return e.Err
+}
+
+type handshake struct {
+	protoVersion int
+	addr         handshakeAddr
+	serverCert   *x509.Certificate
+}
+
+type handshakeAddr struct {
+	network string
+	addr    string
+}
+
+func (a handshakeAddr) Network() string {
+	return a.network
+}
+
+func (a handshakeAddr) String() string {
+	return a.addr
+}
+
+// parseHandshake parses the handshake line that a plugin writes to its
+// stdout once it's ready to accept connections, which has the following
+// format when using AutoMTLS:
+//
+//	CORE-VERSION|PROTOCOL-VERSION|NETWORK|ADDRESS|grpc|SERVER-CERT
+//
+// SERVER-CERT is the base64 encoding of the DER form of the plugin's
+// server certificate.
+func parseHandshake(line string, protoVersions []int) (*handshake, error) {
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) < 5 {
+		return nil, fmt.Errorf("invalid plugin handshake %q", line)
+	}
+	if parts[0] != "1" {
+		return nil, fmt.Errorf("unsupported plugin handshake version %q", parts[0])
+	}
+	protoVersion, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol version %q in plugin handshake", parts[1])
+	}
+	if !slices.Contains(protoVersions, protoVersion) {
+		return nil, ProtocolVersionError{Selected: protoVersion, Offered: protoVersions}
+	}
+	if parts[4] != "grpc" {
+		return nil, fmt.Errorf("unsupported plugin transport protocol %q", parts[4])
+	}
+	if len(parts) < 6 || parts[5] == "" {
+		return nil, fmt.Errorf("plugin did not return a server certificate; it might not support automatic mTLS")
+	}
+	// go-plugin uses unpadded base64, but we'll tolerate padding too.
+	certDER, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(parts[5], "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server certificate in plugin handshake: %w", err)
+	}
+	serverCert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server certificate in plugin handshake: %w", err)
+	}
+	return &handshake{
+		protoVersion: protoVersion,
+		addr:         handshakeAddr{network: parts[2], addr: parts[3]},
+		serverCert:   serverCert,
+	}, nil
+}
+
+// generateCert generates an ephemeral self-signed certificate for the client
+// side of an AutoMTLS connection, returning both the certificate itself and
+// its PEM encoding to send to the plugin.
+//
+// The plugin uses this certificate as the root of trust for verifying the
+// client, so it must be a CA certificate.
+func generateCert() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: "localhost",
+		},
+		DNSNames:              []string{"localhost"},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             now.Add(-30 * time.Second),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return cert, certPEM, nil
+}
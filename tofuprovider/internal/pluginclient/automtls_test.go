@@ -0,0 +1,360 @@
+package pluginclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+)
+
+func TestParseHandshake(t *testing.T) {
+	// The certificate's length varies, so we'll generate certificates until
+	// we find one that needs padding, so that we can test both encodings.
+	var cert *x509.Certificate
+	for cert == nil || len(cert.Raw)%3 == 0 {
+		_, certPEM, err := generateCert()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert = mustParseCertPEM(t, certPEM)
+	}
+	unpadded := base64.RawStdEncoding.EncodeToString(cert.Raw)
+	padded := base64.StdEncoding.EncodeToString(cert.Raw)
+
+	tests := map[string]struct {
+		line     string
+		offered  []int
+		wantAddr handshakeAddr
+		wantVer  int
+		wantErr  string
+	}{
+		"unpadded certificate": {
+			line:     "1|6|unix|/tmp/plugin123|grpc|" + unpadded,
+			offered:  []int{5, 6},
+			wantAddr: handshakeAddr{network: "unix", addr: "/tmp/plugin123"},
+			wantVer:  6,
+		},
+		"padded certificate": {
+			line:     "1|5|tcp|127.0.0.1:1234|grpc|" + padded + "\n",
+			offered:  []int{5, 6},
+			wantAddr: handshakeAddr{network: "tcp", addr: "127.0.0.1:1234"},
+			wantVer:  5,
+		},
+		"missing certificate": {
+			line:    "1|6|unix|/tmp/plugin123|grpc",
+			offered: []int{6},
+			wantErr: "plugin did not return a server certificate",
+		},
+		"empty certificate": {
+			line:    "1|6|unix|/tmp/plugin123|grpc|",
+			offered: []int{6},
+			wantErr: "plugin did not return a server certificate",
+		},
+		"invalid certificate": {
+			line:    "1|6|unix|/tmp/plugin123|grpc|" + base64.RawStdEncoding.EncodeToString([]byte("not a certificate")),
+			offered: []int{6},
+			wantErr: "invalid server certificate in plugin handshake",
+		},
+		"version not offered": {
+			line:    "1|5|unix|/tmp/plugin123|grpc|" + unpadded,
+			offered: []int{6},
+			wantErr: "plugin selected protocol version 5, but the client offered only 6",
+		},
+		"netrpc transport": {
+			line:    "1|6|unix|/tmp/plugin123|netrpc|" + unpadded,
+			offered: []int{6},
+			wantErr: `unsupported plugin transport protocol "netrpc"`,
+		},
+		"unsupported core version": {
+			line:    "2|6|unix|/tmp/plugin123|grpc|" + unpadded,
+			offered: []int{6},
+			wantErr: `unsupported plugin handshake version "2"`,
+		},
+		"invalid protocol version": {
+			line:    "1|six|unix|/tmp/plugin123|grpc|" + unpadded,
+			offered: []int{6},
+			wantErr: `invalid protocol version "six"`,
+		},
+		"too few fields": {
+			line:    "1|6|unix",
+			offered: []int{6},
+			wantErr: "invalid plugin handshake",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseHandshake(test.line, test.offered)
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error containing %q", test.wantErr)
+				}
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.protoVersion != test.wantVer {
+				t.Errorf("wrong protocol version %d; want %d", got.protoVersion, test.wantVer)
+			}
+			if got.addr != test.wantAddr {
+				t.Errorf("wrong address %#v; want %#v", got.addr, test.wantAddr)
+			}
+			if !got.serverCert.Equal(cert) {
+				t.Errorf("wrong server certificate")
+			}
+		})
+	}
+
+	t.Run("version error type", func(t *testing.T) {
+		_, err := parseHandshake("1|5|unix|/tmp/plugin123|grpc|"+unpadded, []int{6})
+		var versionErr ProtocolVersionError
+		if !errors.As(err, &versionErr) {
+			t.Fatalf("wrong error type %T", err)
+		}
+		want := ProtocolVersionError{Selected: 5, Offered: []int{6}}
+		if !reflect.DeepEqual(versionErr, want) {
+			t.Errorf("wrong error\ngot:  %#v\nwant: %#v", versionErr, want)
+		}
+	})
+}
+
+func TestStartAutoMTLS(t *testing.T) {
+	plugin, protoVersion, conn, err := StartAutoMTLS(context.Background(), &AutoMTLSConfig{
+		Cmd:           helperCommand(t, "automtls"),
+		CookieKey:     "TEST_COOKIE",
+		CookieValue:   "test",
+		ProtoVersions: []int{5, 6},
+		Stderr:        NewStderrTail(nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Close()
+	if protoVersion != 6 {
+		t.Errorf("wrong protocol version %d; want 6", protoVersion)
+	}
+
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+	resp, err := client.GetMetadata(context.Background(), &tfplugin6.GetMetadata_Request{})
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if got, want := len(resp.DataSources), 1; got != want {
+		t.Errorf("wrong number of data sources %d; want %d", got, want)
+	}
+
+	if err := plugin.Close(); err != nil {
+		t.Errorf("failed to close: %s", err)
+	}
+	if exitCode, exited := plugin.ExitStatus(); !exited || exitCode != 0 {
+		t.Errorf("wrong exit status (%d, %t); want (0, true)", exitCode, exited)
+	}
+}
+
+func TestStartAutoMTLSWrongServerCert(t *testing.T) {
+	plugin, _, conn, err := StartAutoMTLS(context.Background(), &AutoMTLSConfig{
+		Cmd:           helperCommand(t, "automtls-wrong-cert"),
+		CookieKey:     "TEST_COOKIE",
+		CookieValue:   "test",
+		ProtoVersions: []int{6},
+		Stderr:        NewStderrTail(nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+	_, err = client.GetMetadata(ctx, &tfplugin6.GetMetadata_Request{}, grpc.WaitForReady(false))
+	if err == nil {
+		t.Fatal("request succeeded despite the server presenting the wrong certificate")
+	}
+	if !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("error does not mention the certificate problem: %s", err)
+	}
+}
+
+func TestStartAutoMTLSVersionNotOffered(t *testing.T) {
+	_, _, _, err := StartAutoMTLS(context.Background(), &AutoMTLSConfig{
+		Cmd:           helperCommand(t, "automtls"),
+		CookieKey:     "TEST_COOKIE",
+		CookieValue:   "test",
+		ProtoVersions: []int{5},
+		Stderr:        NewStderrTail(nil, nil),
+	})
+	var versionErr ProtocolVersionError
+	if !errors.As(err, &versionErr) {
+		t.Fatalf("wrong error %v; want ProtocolVersionError", err)
+	}
+	if versionErr.Selected != 6 {
+		t.Errorf("wrong selected version %d; want 6", versionErr.Selected)
+	}
+}
+
+func TestStartAutoMTLSCloseWithOrphanedStderr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for autoMTLSShutdownTimeout")
+	}
+
+	// This helper starts a subprocess that inherits its stderr and outlives
+	// it, and doesn't respond to the shutdown request.
+	plugin, _, _, err := StartAutoMTLS(context.Background(), &AutoMTLSConfig{
+		Cmd:           helperCommand(t, "automtls-orphan"),
+		CookieKey:     "TEST_COOKIE",
+		CookieValue:   "test",
+		ProtoVersions: []int{6},
+		Stderr:        NewStderrTail(nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		plugin.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(4*autoMTLSShutdownTimeout + 5*time.Second):
+		t.Fatal("Close did not return")
+	}
+}
+
+// runAutoMTLSHelper behaves as a minimal provider plugin supporting the
+// AutoMTLS handshake, for [TestHelperProcess]. It always selects protocol
+// version 6, as a plugin that supports only that version would.
+//
+// If behavior is "automtls-wrong-cert" then the certificate in the handshake
+// is not the one the server actually uses. If behavior is "automtls-orphan"
+// then the helper starts a long-running subprocess that inherits its stderr
+// and doesn't offer the controller service for shutting down.
+func runAutoMTLSHelper(behavior string) {
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if os.Getenv("TEST_COOKIE") != "test" {
+		fail(fmt.Errorf("missing cookie"))
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM([]byte(os.Getenv("PLUGIN_CLIENT_CERT"))) {
+		fail(fmt.Errorf("missing client certificate"))
+	}
+	cert, certPEM, err := generateCert()
+	if err != nil {
+		fail(err)
+	}
+	handshakePEM := certPEM
+	if behavior == "automtls-wrong-cert" {
+		_, handshakePEM, err = generateCert()
+		if err != nil {
+			fail(err)
+		}
+	}
+	handshakeCert, err := parseCertPEM(handshakePEM)
+	if err != nil {
+		fail(err)
+	}
+	if behavior == "automtls-orphan" {
+		orphan := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		orphan.Env = append(os.Environ(), helperEnv+"=sleep")
+		orphan.Stderr = os.Stderr
+		if err := orphan.Start(); err != nil {
+			fail(err)
+		}
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fail(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})))
+	tfplugin6.RegisterProviderServer(srv, autoMTLSHelperProvider{})
+	if behavior != "automtls-orphan" {
+		srv.RegisterService(&grpcControllerServiceDesc, struct{}{})
+	}
+
+	fmt.Printf("1|6|tcp|%s|grpc|%s\n", lis.Addr(), base64.RawStdEncoding.EncodeToString(handshakeCert.Raw))
+	if err := srv.Serve(lis); err != nil {
+		fail(err)
+	}
+	os.Exit(0)
+}
+
+type autoMTLSHelperProvider struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (autoMTLSHelperProvider) GetMetadata(ctx context.Context, req *tfplugin6.GetMetadata_Request) (*tfplugin6.GetMetadata_Response, error) {
+	return &tfplugin6.GetMetadata_Response{
+		DataSources: []*tfplugin6.GetMetadata_DataSourceMetadata{
+			{TypeName: "test"},
+		},
+	}, nil
+}
+
+// grpcControllerServiceDesc describes the controller service that plugins
+// built with go-plugin offer, which the client uses to ask the plugin to
+// shut down.
+var grpcControllerServiceDesc = grpc.ServiceDesc{
+	ServiceName: "plugin.GRPCController",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Shutdown",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				if err := dec(&emptypb.Empty{}); err != nil {
+					return nil, err
+				}
+				// Give the response a chance to be sent before exiting.
+				time.AfterFunc(10*time.Millisecond, func() { os.Exit(0) })
+				return &emptypb.Empty{}, nil
+			},
+		},
+	},
+}
+
+func mustParseCertPEM(t *testing.T, src []byte) *x509.Certificate {
+	t.Helper()
+	cert, err := parseCertPEM(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func parseCertPEM(src []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(src)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
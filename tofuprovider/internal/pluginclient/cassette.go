@@ -0,0 +1,295 @@
+package pluginclient
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+// A cassette is a recording of the requests made to a plugin and the
+// plugin's responses, which can be replayed later without running the plugin.
+//
+// Cassettes use the JSON Lines format. The first line is a header object
+// describing the cassette itself, and each subsequent line is an interaction
+// object describing a single request and its outcome. Request and response
+// messages use the protobuf JSON mapping, so that the raw bytes of fields
+// such as DynamicValue.msgpack and private data are preserved exactly.
+//
+// Interactions appear in the order the requests completed, which is not
+// necessarily the order they were made if the caller made concurrent
+// requests.
+
+// cassetteFormat identifies a cassette in its header.
+const cassetteFormat = "opentofu-provider-session"
+
+// CassetteVersion is the version of the cassette format that [NewRecorder]
+// writes and that [ReadCassette] accepts. This must be incremented whenever
+// the format changes in a way that older versions could not read.
+const CassetteVersion = 1
+
+type cassetteHeader struct {
+	Format          string `json:"format"`
+	Version         int    `json:"version"`
+	ProtocolVersion int    `json:"protocolVersion"`
+}
+
+type cassetteInteraction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *cassetteError  `json:"error,omitempty"`
+}
+
+type cassetteError struct {
+	Code    grpcCodes.Code `json:"code"`
+	Message string         `json:"message"`
+}
+
+// Recorder writes the requests made to a plugin, and the plugin's responses,
+// to a cassette. Use [Plugin.SetRecorder] to record the requests made to
+// a plugin.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error // the first error encountered while writing, if any
+}
+
+// NewRecorder returns a [Recorder] that writes a cassette to w, for a plugin
+// using the given protocol major version.
+//
+// The cassette header is written immediately. Errors writing to w do not
+// cause requests to fail, but are instead reported by [Recorder.Err].
+func NewRecorder(w io.Writer, protoVersion int) *Recorder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	r := &Recorder{enc: enc}
+	r.err = enc.Encode(cassetteHeader{
+		Format:          cassetteFormat,
+		Version:         CassetteVersion,
+		ProtocolVersion: protoVersion,
+	})
+	return r
+}
+
+// Err returns the first error encountered while writing the cassette, or nil
+// if all writes have succeeded so far.
+//
+// Err can be called on a nil *Recorder, always returning nil.
+func (r *Recorder) Err() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record writes a single interaction to the cassette, where err is the error
+// returned by the request, if any, and reply is the response message. ctx is
+// the context the request was made with.
+//
+// Only errors that the plugin returned are recorded. A request that failed
+// because ctx was canceled or reached its deadline, or that failed on the
+// client side without a gRPC status, is not recorded at all, because its
+// outcome didn't depend on the plugin.
+//
+// record can be called on a nil *Recorder, doing nothing.
+func (r *Recorder) record(ctx context.Context, method string, args, reply any, err error) {
+	if r == nil {
+		return
+	}
+	interaction := cassetteInteraction{Method: method}
+	var marshalErr error
+	interaction.Request, marshalErr = marshalMessage(args)
+	if err != nil {
+		status, ok := grpcStatus.FromError(err)
+		if !ok || ctx.Err() != nil {
+			return
+		}
+		interaction.Error = &cassetteError{
+			Code:    status.Code(),
+			Message: status.Message(),
+		}
+	} else if marshalErr == nil {
+		interaction.Response, marshalErr = marshalMessage(reply)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return // the cassette is already incomplete, so there's no point
+	}
+	if marshalErr != nil {
+		r.err = fmt.Errorf("failed to record %s: %w", method, marshalErr)
+		return
+	}
+	r.err = r.enc.Encode(interaction)
+}
+
+// ReplayConn is a [grpc.ClientConnInterface] that responds to requests using
+// the interactions from a cassette, instead of sending them to a plugin.
+//
+// Closing a ReplayConn reports any interactions that were never used.
+type ReplayConn struct {
+	mu           sync.Mutex
+	interactions []*cassetteInteraction // nil elements have already been used
+}
+
+// ReadCassette reads a cassette previously written by a [Recorder], returning
+// the protocol major version of the recorded plugin and a connection that
+// replays the recorded interactions.
+func ReadCassette(r io.Reader) (int, *ReplayConn, error) {
+	sc := bufio.NewScanner(r)
+	// Responses such as GetProviderSchema can be very large when the
+	// provider has many resource types, so we need a generous line limit.
+	sc.Buffer(nil, 256*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return 0, nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		return 0, nil, fmt.Errorf("cassette is empty")
+	}
+	var header cassetteHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.Format != cassetteFormat {
+		return 0, nil, fmt.Errorf("invalid cassette header")
+	}
+	if header.Version != CassetteVersion {
+		return 0, nil, fmt.Errorf("unsupported cassette version %d", header.Version)
+	}
+
+	conn := &ReplayConn{}
+	for line := 2; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		interaction := &cassetteInteraction{}
+		if err := json.Unmarshal(sc.Bytes(), interaction); err != nil {
+			return 0, nil, fmt.Errorf("invalid cassette interaction on line %d: %w", line, err)
+		}
+		if interaction.Method == "" || (interaction.Response == nil && interaction.Error == nil) {
+			return 0, nil, fmt.Errorf("invalid cassette interaction on line %d: must have method and either response or error", line)
+		}
+		conn.interactions = append(conn.interactions, interaction)
+	}
+	if err := sc.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return header.ProtocolVersion, conn, nil
+}
+
+// Invoke implements grpc.ClientConnInterface.
+//
+// Each recorded interaction is used at most once. If more than one unused
+// interaction matches the request then the earliest is used. If none match
+// then the result is a [providerops.UnmatchedRequestError].
+func (c *ReplayConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	req, ok := args.(proto.Message)
+	if !ok {
+		return fmt.Errorf("cannot replay %s: request is not a protobuf message", method)
+	}
+
+	c.mu.Lock()
+	var found *cassetteInteraction
+	for i, interaction := range c.interactions {
+		if interaction == nil || interaction.Method != method {
+			continue
+		}
+		recorded := req.ProtoReflect().New().Interface()
+		if err := protojson.Unmarshal(interaction.Request, recorded); err != nil {
+			continue // can't match something we can't decode
+		}
+		if proto.Equal(recorded, req) {
+			found = interaction
+			c.interactions[i] = nil
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if found == nil {
+		reqJSON, _ := marshalMessage(args)
+		return providerops.UnmatchedRequestError{
+			Operation:    lookupOperation(method).name,
+			ResourceType: requestResourceType(args),
+			Request:      string(reqJSON),
+		}
+	}
+	if found.Error != nil {
+		return grpcStatus.Error(found.Error.Code, found.Error.Message)
+	}
+	resp, ok := reply.(proto.Message)
+	if !ok {
+		return fmt.Errorf("cannot replay %s: response is not a protobuf message", method)
+	}
+	if err := protojson.Unmarshal(found.Response, resp); err != nil {
+		return fmt.Errorf("invalid recorded response for %s: %w", method, err)
+	}
+	return nil
+}
+
+// NewStream implements grpc.ClientConnInterface.
+//
+// Cassettes cannot include streaming requests, and so this always fails.
+func (c *ReplayConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, fmt.Errorf("cannot replay %s: streaming requests are not supported in recordings", lookupOperation(method).name)
+}
+
+// Remaining returns a description of each of the recorded interactions that
+// have not yet been used, in the order they were recorded.
+func (c *ReplayConn) Remaining() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ret []string
+	for _, interaction := range c.interactions {
+		if interaction == nil {
+			continue
+		}
+		desc := lookupOperation(interaction.Method).name
+		var req struct {
+			TypeName       string `json:"typeName"`
+			TargetTypeName string `json:"targetTypeName"`
+		}
+		_ = json.Unmarshal(interaction.Request, &req) // best effort only
+		if typeName := cmp.Or(req.TypeName, req.TargetTypeName); typeName != "" {
+			desc = fmt.Sprintf("%s for resource type %q", desc, typeName)
+		}
+		ret = append(ret, desc)
+	}
+	return ret
+}
+
+// Close implements [io.Closer], returning an error if any of the recorded
+// interactions were never used, which typically means that the code under
+// test made fewer requests than when the cassette was recorded.
+func (c *ReplayConn) Close() error {
+	remaining := c.Remaining()
+	if len(remaining) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d recorded requests were not replayed: %s", len(remaining), strings.Join(remaining, "; "))
+}
+
+// marshalMessage returns the JSON representation of the given protobuf
+// message, as used in cassettes.
+func marshalMessage(msg any) (json.RawMessage, error) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil, errors.New("not a protobuf message")
+	}
+	return protojson.Marshal(m)
+}
@@ -0,0 +1,359 @@
+package pluginclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+// cassetteTestProvider is a provider server with deterministic responses,
+// for recording.
+type cassetteTestProvider struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (cassetteTestProvider) ConfigureProvider(ctx context.Context, req *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+	return &tfplugin6.ConfigureProvider_Response{}, nil
+}
+
+func (cassetteTestProvider) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	if req.TypeName == "test_fail" {
+		return nil, grpcStatus.Error(grpcCodes.Internal, "data source failed")
+	}
+	return &tfplugin6.ReadDataSource_Response{
+		// The state is the config, with its msgpack bytes reversed so that
+		// we can tell that the raw bytes survive recording and replay.
+		State: &tfplugin6.DynamicValue{
+			Msgpack: reversed(req.Config.GetMsgpack()),
+		},
+		Diagnostics: []*tfplugin6.Diagnostic{
+			{
+				Severity: tfplugin6.Diagnostic_WARNING,
+				Summary:  "read " + req.TypeName,
+			},
+		},
+	}, nil
+}
+
+func (cassetteTestProvider) PlanResourceChange(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+	return &tfplugin6.PlanResourceChange_Response{
+		PlannedState:   req.ProposedNewState,
+		PlannedPrivate: []byte("\x00private\xff"),
+	}, nil
+}
+
+func reversed(b []byte) []byte {
+	ret := make([]byte, len(b))
+	for i, c := range b {
+		ret[len(b)-1-i] = c
+	}
+	return ret
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	configureReq := &tfplugin6.ConfigureProvider_Request{
+		Config: &tfplugin6.DynamicValue{Msgpack: []byte("\x81\xa5token\xa6secret")},
+	}
+	readReqs := []*tfplugin6.ReadDataSource_Request{
+		{TypeName: "test_a", Config: &tfplugin6.DynamicValue{Msgpack: []byte{0x81, 0xa1, 'a', 0x01}}},
+		{TypeName: "test_b", Config: &tfplugin6.DynamicValue{Msgpack: []byte{0x81, 0xa1, 'b', 0x02}}},
+		// The same request twice, to check that each recorded response is
+		// used only once.
+		{TypeName: "test_a", Config: &tfplugin6.DynamicValue{Msgpack: []byte{0x81, 0xa1, 'a', 0x01}}},
+	}
+	planReq := &tfplugin6.PlanResourceChange_Request{
+		TypeName:         "test_thing",
+		ProposedNewState: &tfplugin6.DynamicValue{Msgpack: []byte{0x80}},
+	}
+	failReq := &tfplugin6.ReadDataSource_Request{TypeName: "test_fail"}
+
+	// First we'll record a session with a real server.
+	var cassette bytes.Buffer
+	var recorded []proto.Message
+	{
+		conn := startTestServer(t, cassetteTestProvider{}, nil)
+		plugin := New(nil)
+		plugin.SetRecorder(NewRecorder(&cassette, 6))
+		client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+		if _, err := client.ConfigureProvider(ctx, configureReq); err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range readReqs {
+			resp, err := client.ReadDataSource(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded = append(recorded, resp)
+		}
+		resp, err := client.PlanResourceChange(ctx, planReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, resp)
+		if _, err := client.ReadDataSource(ctx, failReq); err == nil {
+			t.Fatal("test_fail succeeded")
+		}
+		if err := plugin.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := strings.Count(cassette.String(), "\n"), 7; got != want {
+		t.Fatalf("cassette has %d lines; want %d\n%s", got, want, cassette.String())
+	}
+
+	// Now we'll replay the recording, making the same requests in a
+	// different order.
+	protoVersion, replayConn, err := ReadCassette(&cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protoVersion != 6 {
+		t.Errorf("wrong protocol version %d; want 6", protoVersion)
+	}
+	plugin := New(nil)
+	client := tfplugin6.NewProviderClient(plugin.Conn(replayConn, nil))
+
+	resp, err := client.PlanResourceChange(ctx, planReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(resp, recorded[3]) {
+		t.Errorf("wrong PlanResourceChange response\ngot:  %s\nwant: %s", resp, recorded[3])
+	}
+	for i := len(readReqs) - 1; i >= 0; i-- {
+		resp, err := client.ReadDataSource(ctx, readReqs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(resp, recorded[i]) {
+			t.Errorf("wrong ReadDataSource response %d\ngot:  %s\nwant: %s", i, resp, recorded[i])
+		}
+	}
+	if _, err := client.ConfigureProvider(ctx, configureReq); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ReadDataSource(ctx, failReq)
+	if got, want := grpcStatus.Code(err), grpcCodes.Internal; got != want {
+		t.Errorf("wrong status code for recorded error %s; want %s", got, want)
+	}
+	if got, want := grpcStatus.Convert(err).Message(), "data source failed"; got != want {
+		t.Errorf("wrong message for recorded error %q; want %q", got, want)
+	}
+
+	// All of the recorded interactions have now been used, so repeating
+	// any of them must fail.
+	_, err = client.ReadDataSource(ctx, readReqs[0])
+	var unmatched providerops.UnmatchedRequestError
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("wrong error %v; want UnmatchedRequestError", err)
+	}
+	if unmatched.Operation != "ReadDataResource" || unmatched.ResourceType != "test_a" {
+		t.Errorf("wrong error details %#v", unmatched)
+	}
+	if !strings.Contains(unmatched.Request, `"typeName":"test_a"`) {
+		t.Errorf("Request field does not describe the request: %s", unmatched.Request)
+	}
+
+	_, err = client.ConfigureProvider(ctx, configureReq)
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("wrong error %v; want UnmatchedRequestError", err)
+	}
+	if got, want := err.Error(), "no recorded response for ConfigureProvider request"; got != want {
+		t.Errorf("wrong error message\ngot:  %s\nwant: %s", got, want)
+	}
+	if err := replayConn.Close(); err != nil {
+		t.Errorf("unexpected error closing fully-used replay: %s", err)
+	}
+
+	// A request that differs only in its raw msgpack bytes doesn't match.
+	_, replayConn, err = ReadCassette(strings.NewReader(cassetteHeaderLine + `
+{"method":"/tfplugin6.Provider/ReadDataSource","request":{"typeName":"test_b","config":{"msgpack":"gaFhAQ=="}},"response":{}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = tfplugin6.NewProviderClient(New(nil).Conn(replayConn, nil))
+	_, err = client.ReadDataSource(ctx, readReqs[1])
+	if !errors.As(err, &unmatched) {
+		t.Errorf("wrong error %v; want UnmatchedRequestError", err)
+	}
+
+	// The unmatched interaction is still waiting to be used.
+	if got, want := replayConn.Remaining(), []string{`ReadDataResource for resource type "test_b"`}; !slices.Equal(got, want) {
+		t.Errorf("wrong remaining interactions\ngot:  %q\nwant: %q", got, want)
+	}
+	err = replayConn.Close()
+	if got, want := fmt.Sprint(err), `1 recorded requests were not replayed: ReadDataResource for resource type "test_b"`; got != want {
+		t.Errorf("wrong error from Close\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestRecorderSkipsClientSideErrors(t *testing.T) {
+	conn := startTestServer(t, connTestProvider{}, nil)
+	var cassette bytes.Buffer
+	plugin := New(nil)
+	plugin.SetRecorder(NewRecorder(&cassette, 6))
+	plugin.SetTimeoutPolicy(&providerops.TimeoutPolicy{
+		Timeouts: map[providerops.OperationKind]time.Duration{
+			providerops.OperationRead: 50 * time.Millisecond,
+		},
+	})
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+	req := &tfplugin6.ReadResource_Request{TypeName: "test"}
+
+	// connTestProvider never responds to ReadResource, so each of these
+	// requests fails only because of something on the client side.
+	if _, err := client.ReadResource(context.Background(), req); !errors.As(err, new(providerops.TimeoutError)) {
+		t.Errorf("wrong error %v; want TimeoutError", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.ReadResource(ctx, req); grpcStatus.Code(err) != grpcCodes.Canceled {
+		t.Errorf("wrong error %v; want Canceled", err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		// The empty policy overrides the plugin's default timeout, so this
+		// request waits until the plugin is closed.
+		_, err := client.ReadResource(providerops.ContextWithTimeoutPolicy(context.Background(), &providerops.TimeoutPolicy{}), req)
+		errCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // give the request time to start
+	if err := plugin.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; !errors.Is(err, ErrClosed) {
+		t.Errorf("wrong error %v; want ErrClosed", err)
+	}
+
+	if got, want := cassette.String(), cassetteHeaderLine+"\n"; got != want {
+		t.Errorf("wrong cassette\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestCassetteStreams(t *testing.T) {
+	ctx := context.Background()
+	conn := startTestServer(t, nil, connTestProvisioner{})
+	plugin := New(nil)
+	plugin.SetRecorder(NewRecorder(io.Discard, 5))
+	client := tfplugin5.NewProvisionerClient(plugin.Conn(conn, nil))
+	_, err := client.ProvisionResource(ctx, &tfplugin5.ProvisionResource_Request{})
+	if got, want := fmt.Sprint(err), "cannot record ProvisionResource: streaming requests are not supported in recordings"; got != want {
+		t.Errorf("wrong error when recording\ngot:  %s\nwant: %s", got, want)
+	}
+
+	_, replayConn, err := ReadCassette(strings.NewReader(`{"format":"opentofu-provider-session","version":1,"protocolVersion":5}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = tfplugin5.NewProvisionerClient(New(nil).Conn(replayConn, nil))
+	_, err = client.ProvisionResource(ctx, &tfplugin5.ProvisionResource_Request{})
+	if got, want := fmt.Sprint(err), "cannot replay ProvisionResource: streaming requests are not supported in recordings"; got != want {
+		t.Errorf("wrong error when replaying\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+const cassetteHeaderLine = `{"format":"opentofu-provider-session","version":1,"protocolVersion":6}`
+
+func TestReadCassette(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		wantErr string
+	}{
+		"header only": {
+			input: cassetteHeaderLine + "\n",
+		},
+		"blank lines": {
+			input: cassetteHeaderLine + "\n\n" + `{"method":"/tfplugin6.Provider/StopProvider","request":{},"response":{}}` + "\n\n",
+		},
+		"recorded error": {
+			input: cassetteHeaderLine + "\n" + `{"method":"/tfplugin6.Provider/StopProvider","request":{},"error":{"code":14,"message":"unavailable"}}` + "\n",
+		},
+		"empty": {
+			input:   "",
+			wantErr: "cassette is empty",
+		},
+		"not JSON": {
+			input:   "hello\n",
+			wantErr: "invalid cassette header",
+		},
+		"wrong format": {
+			input:   `{"format":"something-else","version":1}` + "\n",
+			wantErr: "invalid cassette header",
+		},
+		"future version": {
+			input:   `{"format":"opentofu-provider-session","version":2,"protocolVersion":6}` + "\n",
+			wantErr: "unsupported cassette version 2",
+		},
+		"invalid interaction": {
+			input:   cassetteHeaderLine + "\n" + `{"method":` + "\n",
+			wantErr: "invalid cassette interaction on line 2",
+		},
+		"interaction without outcome": {
+			input:   cassetteHeaderLine + "\n" + `{"method":"/tfplugin6.Provider/StopProvider","request":{}}` + "\n",
+			wantErr: "must have method and either response or error",
+		},
+		"interaction without method": {
+			input:   cassetteHeaderLine + "\n\n" + `{"request":{},"response":{}}` + "\n",
+			wantErr: "invalid cassette interaction on line 3",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ReadCassette(strings.NewReader(test.input))
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error containing %q", test.wantErr)
+				}
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestRecorderWriteError(t *testing.T) {
+	conn := startTestServer(t, cassetteTestProvider{}, nil)
+	plugin := New(nil)
+	plugin.SetRecorder(NewRecorder(failingWriter{}, 6))
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+	// The request itself succeeds, but closing reports the problem.
+	if _, err := client.ConfigureProvider(context.Background(), &tfplugin6.ConfigureProvider_Request{}); err != nil {
+		t.Fatal(err)
+	}
+	err := plugin.Close()
+	if err == nil || !strings.Contains(err.Error(), "failed to record requests") {
+		t.Errorf("wrong error from Close: %v", err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,173 @@
+package pluginclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+)
+
+// These tests are most useful when run with the race detector enabled.
+
+// closeTestProvider is a provider server whose ReadDataSource responds
+// immediately for the type name "fast" and otherwise blocks until the
+// request is canceled.
+type closeTestProvider struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (closeTestProvider) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	if req.TypeName == "fast" {
+		return &tfplugin6.ReadDataSource_Response{}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// closeTestProvisioner is a provisioner server whose ProvisionResource sends
+// one message and then blocks until the request is canceled.
+type closeTestProvisioner struct {
+	tfplugin5.UnimplementedProvisionerServer
+}
+
+func (closeTestProvisioner) ProvisionResource(req *tfplugin5.ProvisionResource_Request, stream tfplugin5.Provisioner_ProvisionResourceServer) error {
+	if err := stream.Send(&tfplugin5.ProvisionResource_Response{Output: "started"}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func TestPluginCloseConcurrentRequests(t *testing.T) {
+	conn := startTestServer(t, closeTestProvider{}, nil)
+	plugin := New(conn)
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		typeName := "slow"
+		if i%2 == 0 {
+			typeName = "fast"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.ReadDataSource(context.Background(), &tfplugin6.ReadDataSource_Request{
+				TypeName: typeName,
+			})
+			errs <- err
+		}()
+	}
+
+	// Give at least some of the requests a chance to start before we close.
+	time.Sleep(50 * time.Millisecond)
+	var closeWg sync.WaitGroup
+	for range 3 {
+		// Close is also safe to call concurrently with itself.
+		closeWg.Add(1)
+		go func() {
+			defer closeWg.Done()
+			plugin.Close()
+		}()
+	}
+	closeWg.Wait()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil && !errors.Is(err, ErrClosed) {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	plugin.mu.Lock()
+	inflight := plugin.inflight
+	plugin.mu.Unlock()
+	if inflight != 0 {
+		t.Errorf("%d requests still in flight after Close", inflight)
+	}
+}
+
+func TestPluginRequestAfterClose(t *testing.T) {
+	conn := startTestServer(t, closeTestProvider{}, closeTestProvisioner{})
+	plugin := New(conn)
+	providerClient := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+	provisionerClient := tfplugin5.NewProvisionerClient(plugin.Conn(conn, nil))
+
+	if err := plugin.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := providerClient.ReadDataSource(context.Background(), &tfplugin6.ReadDataSource_Request{
+		TypeName: "fast",
+	})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("wrong error from Invoke: %v; want ErrClosed", err)
+	}
+	_, err = provisionerClient.ProvisionResource(context.Background(), &tfplugin5.ProvisionResource_Request{})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("wrong error from NewStream: %v; want ErrClosed", err)
+	}
+}
+
+func TestPluginCloseWaitsForStream(t *testing.T) {
+	conn := startTestServer(t, nil, closeTestProvisioner{})
+
+	// The closer checks that the stream has released its in-flight count
+	// before the connection is closed.
+	var plugin *Plugin
+	var inflightAtClose int
+	plugin = New(closerFunc(func() error {
+		plugin.mu.Lock()
+		inflightAtClose = plugin.inflight
+		plugin.mu.Unlock()
+		return conn.Close()
+	}))
+	client := tfplugin5.NewProvisionerClient(plugin.Conn(conn, nil))
+
+	stream, err := client.ProvisionResource(context.Background(), &tfplugin5.ProvisionResource_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	plugin.mu.Lock()
+	inflight := plugin.inflight
+	plugin.mu.Unlock()
+	if inflight != 1 {
+		t.Fatalf("%d requests in flight while stream is open; want 1", inflight)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		plugin.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	if inflightAtClose != 0 {
+		t.Errorf("%d requests still in flight when the connection was closed", inflightAtClose)
+	}
+
+	_, err = stream.Recv()
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("wrong error from Recv: %v; want ErrClosed", err)
+	}
+}
+
+// closerFunc is an [io.Closer] that calls a function.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
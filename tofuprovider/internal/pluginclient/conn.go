@@ -0,0 +1,343 @@
+package pluginclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providertrace"
+)
+
+// crashWaitTimeout is how long we'll wait for a child process to exit after
+// a request fails in a way that suggests that it might have crashed.
+//
+// The failed request is often reported slightly before we notice that the
+// process has exited, so this gives us a chance to catch up. This is also
+// the extra latency for an Unavailable error from a plugin that is still
+// running, so it must be short.
+const crashWaitTimeout = 100 * time.Millisecond
+
+// Conn returns a [grpc.ClientConnInterface] that sends requests using the
+// given connection to the plugin, intercepting them to add behavior that
+// is common to all plugin types and protocol versions.
+//
+// gracefulStop, if non-nil, is the function to call to ask the plugin to
+// stop its in-progress operations when applying a
+// [providerops.TimeoutPolicy].
+func (p *Plugin) Conn(conn grpc.ClientConnInterface, gracefulStop func(context.Context) error) grpc.ClientConnInterface {
+	return &interceptedConn{conn: conn, plugin: p, gracefulStop: gracefulStop}
+}
+
+type interceptedConn struct {
+	conn         grpc.ClientConnInterface
+	plugin       *Plugin
+	gracefulStop func(context.Context) error
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (c *interceptedConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	ctx, trace := startOperationTrace(ctx, method, args)
+	err := c.invoke(ctx, lookupOperation(method), method, args, reply, opts...)
+	if !trace.wantsEnd() {
+		return err
+	}
+	var errorDiags, warningDiags int
+	if err == nil {
+		errorDiags, warningDiags = countDiagnostics(reply)
+	}
+	trace.end(err, errorDiags, warningDiags)
+	return err
+}
+
+// operationTrace tracks a single request for the tracer from the context
+// it was made with, so that the tracer can be notified when it ends.
+type operationTrace struct {
+	ctx    context.Context // the context returned by the OperationStart hook
+	tracer *providertrace.Tracer
+	info   *providertrace.OperationInfo
+	start  time.Time
+}
+
+// startOperationTrace notifies the tracer in the given context, if it's
+// interested, that a request for the given method is starting, and returns
+// the context to use for the request along with an object to use to report
+// the end of the request.
+//
+// args is the request message, or nil for a streaming request whose request
+// message isn't known yet.
+func startOperationTrace(ctx context.Context, method string, args any) (context.Context, *operationTrace) {
+	tracer := providertrace.TracerFromContext(ctx)
+	info := &providertrace.OperationInfo{
+		Operation:       lookupOperation(method).name,
+		ProtocolVersion: methodProtocolVersion(method),
+		ResourceType:    requestResourceType(args),
+	}
+	if tracer.OperationStart != nil {
+		ctx = tracer.OperationStart(ctx, info)
+	}
+	ctx = withPropagationHeaders(ctx, tracer)
+	return ctx, &operationTrace{
+		ctx:    ctx,
+		tracer: tracer,
+		info:   info,
+		start:  time.Now(),
+	}
+}
+
+// wantsEnd returns true if the tracer is interested in the end of the
+// request, so that callers can avoid preparing a result nobody will see.
+func (t *operationTrace) wantsEnd() bool {
+	return t.tracer.OperationEnd != nil || t.tracer.Metrics != nil
+}
+
+// end notifies the tracer, if it's interested, that the request has ended
+// with the given error, which is nil if it succeeded at the transport level,
+// and the given numbers of error and warning diagnostics, and records the
+// request's metrics.
+func (t *operationTrace) end(err error, errorDiags, warningDiags int) {
+	if !t.wantsEnd() {
+		return
+	}
+	result := &providertrace.OperationResult{
+		Duration:           time.Since(t.start),
+		StatusCode:         grpcStatus.Code(err),
+		Err:                err,
+		ErrorDiagnostics:   errorDiags,
+		WarningDiagnostics: warningDiags,
+	}
+	if t.tracer.OperationEnd != nil {
+		t.tracer.OperationEnd(t.ctx, t.info, result)
+	}
+	recordMetrics(t.tracer.Metrics, t.info, result)
+}
+
+// recordMetrics reports the measurements for a completed request to the
+// given metrics hooks, if any.
+func recordMetrics(metrics *providertrace.Metrics, info *providertrace.OperationInfo, result *providertrace.OperationResult) {
+	if metrics == nil {
+		return
+	}
+	labels := providertrace.MetricLabels{
+		ProtocolVersion: info.ProtocolVersion,
+		Operation:       info.Operation,
+		ResourceType:    info.ResourceType,
+		Outcome:         result.Outcome(),
+	}
+	if metrics.Counter != nil {
+		metrics.Counter(providertrace.MetricOperations, labels, 1)
+	}
+	if metrics.Histogram != nil {
+		metrics.Histogram(providertrace.MetricOperationDuration, labels, result.Duration.Seconds())
+	}
+}
+
+// requestResourceType returns the name of the resource type that the given
+// request message relates to, or an empty string if it doesn't relate to a
+// single resource type.
+func requestResourceType(args any) string {
+	switch req := args.(type) {
+	case interface{ GetTypeName() string }:
+		return req.GetTypeName()
+	case interface{ GetTargetTypeName() string }:
+		// MoveResourceState relates to two resource types, but only the
+		// target type belongs to the provider that's handling the request.
+		return req.GetTargetTypeName()
+	default:
+		return ""
+	}
+}
+
+// invoke is the main implementation of [interceptedConn.Invoke], after
+// notifying the tracer that the operation is starting.
+func (c *interceptedConn) invoke(ctx context.Context, op operation, method string, args any, reply any, opts ...grpc.CallOption) error {
+	if err := c.plugin.beginRequest(); err != nil {
+		return err
+	}
+	defer c.plugin.endRequest()
+	reqCtx, cancel := c.plugin.requestContext(ctx)
+	defer cancel()
+
+	policy := providerops.TimeoutPolicyFromContext(ctx)
+	if policy == nil {
+		policy = c.plugin.timeoutPolicy
+	}
+	timeout := policy.Timeout(op.kind)
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		reqCtx, cancelTimeout = context.WithTimeout(reqCtx, timeout)
+		defer cancelTimeout()
+		if stopBefore := policy.GracefulStopBefore; stopBefore > 0 && stopBefore < timeout && c.gracefulStop != nil {
+			timer := time.AfterFunc(timeout-stopBefore, func() {
+				stopCtx, cancel := context.WithTimeout(context.Background(), stopBefore)
+				defer cancel()
+				_ = c.gracefulStop(stopCtx)
+			})
+			defer timer.Stop()
+		}
+	}
+
+	err := c.conn.Invoke(reqCtx, method, args, reply, opts...)
+	c.plugin.recorder.record(reqCtx, method, args, reply, err)
+	if err != nil && timeout > 0 && ctx.Err() == nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+		return providerops.TimeoutError{
+			Operation: op.name,
+			Timeout:   timeout,
+			Err:       err,
+		}
+	}
+	return c.plugin.requestError(ctx, err)
+}
+
+// NewStream implements grpc.ClientConnInterface.
+//
+// The tracer is notified that the operation has ended, and its metrics are
+// recorded, once [grpc.ClientStream.RecvMsg] reports the end of the stream
+// or an error. A stream that the caller abandons before then is not
+// reported at all.
+func (c *interceptedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if c.plugin.recorder != nil {
+		return nil, fmt.Errorf("cannot record %s: streaming requests are not supported in recordings", lookupOperation(method).name)
+	}
+	if err := c.plugin.beginRequest(); err != nil {
+		return nil, err
+	}
+	ctx, trace := startOperationTrace(ctx, method, nil)
+	reqCtx, cancel := c.plugin.requestContext(ctx)
+	stream, err := c.conn.NewStream(reqCtx, desc, method, opts...)
+	if err != nil {
+		cancel()
+		c.plugin.endRequest()
+		err = c.plugin.requestError(ctx, err)
+		trace.end(err, 0, 0)
+		return nil, err
+	}
+	// The stream's context is canceled once the stream has finished,
+	// whether successfully or not.
+	context.AfterFunc(stream.Context(), func() {
+		cancel()
+		c.plugin.endRequest()
+	})
+	return &interceptedClientStream{ClientStream: stream, ctx: ctx, plugin: c.plugin, trace: trace}, nil
+}
+
+// withPropagationHeaders returns a child of the given context that includes
+// any trace propagation headers that the given tracer wants to send with a
+// request, as outgoing gRPC metadata.
+func withPropagationHeaders(ctx context.Context, tracer *providertrace.Tracer) context.Context {
+	if tracer.PropagationHeaders == nil {
+		return ctx
+	}
+	headers := tracer.PropagationHeaders(ctx)
+	if len(headers) == 0 {
+		return ctx
+	}
+	// AppendToOutgoingContext converts the names to lowercase for us.
+	kv := make([]string, 0, len(headers)*2)
+	for name, value := range headers {
+		kv = append(kv, name, value)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+type interceptedClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context // the caller's context, before requestContext
+	plugin *Plugin
+	trace  *operationTrace
+
+	// errorDiags and warningDiags count the diagnostics in the messages
+	// received so far, and ended records whether the tracer has been
+	// notified of the end of the stream. gRPC does not allow concurrent
+	// calls to RecvMsg, so these don't need a mutex.
+	errorDiags, warningDiags int
+	ended                    bool
+}
+
+// RecvMsg implements grpc.ClientStream.
+func (s *interceptedClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		if s.trace.wantsEnd() {
+			errs, warnings := countDiagnostics(m)
+			s.errorDiags += errs
+			s.warningDiags += warnings
+		}
+		return nil
+	case errors.Is(err, io.EOF):
+		s.endTrace(nil)
+		return err // end of stream is not a failure
+	default:
+		err = s.plugin.requestError(s.ctx, err)
+		s.endTrace(err)
+		return err
+	}
+}
+
+// endTrace notifies the tracer that the stream has ended, unless it was
+// already notified by an earlier call.
+func (s *interceptedClientStream) endTrace(err error) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.trace.end(err, s.errorDiags, s.warningDiags)
+}
+
+// requestContext returns a child of the given context that is also canceled
+// if the plugin is closed, along with a function that must be called once
+// the request is complete to release the associated resources.
+func (p *Plugin) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(p.closeCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// requestError returns the error that should be returned to the caller when
+// a request to the plugin fails with the given error, which might be nil.
+// ctx is the context the caller passed when making the request.
+//
+// If the request was canceled because the plugin was closed then the result
+// is [ErrClosed]. If the error seems to have been caused by the plugin's child process
+// exiting unexpectedly then the result is a [providerops.ProviderCrashedError]
+// wrapping the given error.
+func (p *Plugin) requestError(ctx context.Context, err error) error {
+	if err != nil && p.closeCtx.Err() != nil && ctx.Err() == nil {
+		return ErrClosed
+	}
+	if err == nil || p.exited == nil || grpcStatus.Code(err) != grpcCodes.Unavailable {
+		return err
+	}
+	select {
+	case <-p.exited:
+	default:
+		timer := time.NewTimer(crashWaitTimeout)
+		defer timer.Stop()
+		select {
+		case <-p.exited:
+		case <-timer.C:
+			return err // the process seems to still be running
+		case <-ctx.Done():
+			return err // the caller doesn't want to wait any longer
+		}
+	}
+	if p.isTerminating() {
+		return err // we terminated the process ourselves, so it didn't crash
+	}
+	return providerops.ProviderCrashedError{
+		ExitCode:   p.exitCode,
+		StderrTail: p.stderr.String(),
+		Err:        err,
+	}
+}
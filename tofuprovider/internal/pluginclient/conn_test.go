@@ -0,0 +1,477 @@
+package pluginclient
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providertrace"
+)
+
+// connTestProvider is a provider server whose ReadResource blocks until the
+// request is canceled.
+type connTestProvider struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (connTestProvider) ReadResource(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestInterceptedConnTimeout(t *testing.T) {
+	tests := map[string]struct {
+		policy *providerops.TimeoutPolicy
+
+		// inContext is true if the policy should be given in the context,
+		// overriding the plugin's default policy.
+		inContext bool
+
+		// wantStopAt is when GracefulStop should be called, or zero if it
+		// should not be called at all.
+		wantStopAt time.Duration
+	}{
+		"plugin default": {
+			policy: &providerops.TimeoutPolicy{
+				Timeouts: map[providerops.OperationKind]time.Duration{
+					providerops.OperationRead: 200 * time.Millisecond,
+					providerops.OperationPlan: time.Minute,
+				},
+			},
+		},
+		"context override": {
+			policy: &providerops.TimeoutPolicy{
+				Timeouts: map[providerops.OperationKind]time.Duration{
+					providerops.OperationRead: 200 * time.Millisecond,
+				},
+			},
+			inContext: true,
+		},
+		"graceful stop": {
+			policy: &providerops.TimeoutPolicy{
+				Timeouts: map[providerops.OperationKind]time.Duration{
+					providerops.OperationRead: 300 * time.Millisecond,
+				},
+				GracefulStopBefore: 200 * time.Millisecond,
+			},
+			wantStopAt: 100 * time.Millisecond,
+		},
+		"graceful stop not before timeout": {
+			policy: &providerops.TimeoutPolicy{
+				Timeouts: map[providerops.OperationKind]time.Duration{
+					providerops.OperationRead: 200 * time.Millisecond,
+				},
+				GracefulStopBefore: 200 * time.Millisecond,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := startTestServer(t, connTestProvider{}, nil)
+			plugin := New(conn)
+			ctx := context.Background()
+			if test.inContext {
+				plugin.SetTimeoutPolicy(&providerops.TimeoutPolicy{
+					Timeouts: map[providerops.OperationKind]time.Duration{
+						providerops.OperationRead: time.Minute,
+					},
+				})
+				ctx = providerops.ContextWithTimeoutPolicy(ctx, test.policy)
+			} else {
+				plugin.SetTimeoutPolicy(test.policy)
+			}
+
+			var mu sync.Mutex
+			var stopCalls []time.Duration
+			start := time.Now()
+			gracefulStop := func(ctx context.Context) error {
+				mu.Lock()
+				stopCalls = append(stopCalls, time.Since(start))
+				mu.Unlock()
+				if _, ok := ctx.Deadline(); !ok {
+					t.Error("GracefulStop context has no deadline")
+				}
+				return nil
+			}
+			client := tfplugin6.NewProviderClient(plugin.Conn(conn, gracefulStop))
+
+			_, err := client.ReadResource(ctx, &tfplugin6.ReadResource_Request{TypeName: "test"})
+			var timeoutErr providerops.TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("wrong error %v; want TimeoutError", err)
+			}
+			if got, want := timeoutErr.Operation, "ReadManagedResource"; got != want {
+				t.Errorf("wrong operation %q; want %q", got, want)
+			}
+			if got, want := timeoutErr.Timeout, test.policy.Timeout(providerops.OperationRead); got != want {
+				t.Errorf("wrong timeout %s; want %s", got, want)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if test.wantStopAt == 0 {
+				if len(stopCalls) != 0 {
+					t.Errorf("GracefulStop called at %s; want no call", stopCalls[0])
+				}
+				return
+			}
+			if len(stopCalls) != 1 {
+				t.Fatalf("GracefulStop called %d times; want 1", len(stopCalls))
+			}
+			if got, timeout := stopCalls[0], test.policy.Timeout(providerops.OperationRead); got < test.wantStopAt || got >= timeout {
+				t.Errorf("GracefulStop called at %s; want between %s and %s", got, test.wantStopAt, timeout)
+			}
+		})
+	}
+}
+
+func TestInterceptedConnCallerCanceled(t *testing.T) {
+	conn := startTestServer(t, connTestProvider{}, nil)
+	plugin := New(conn)
+	plugin.SetTimeoutPolicy(&providerops.TimeoutPolicy{
+		Timeouts: map[providerops.OperationKind]time.Duration{
+			providerops.OperationRead: time.Minute,
+		},
+	})
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := client.ReadResource(ctx, &tfplugin6.ReadResource_Request{TypeName: "test"})
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if errors.As(err, new(providerops.TimeoutError)) {
+		t.Errorf("caller's own deadline reported as a TimeoutError: %s", err)
+	}
+}
+
+func TestRequestResourceType(t *testing.T) {
+	tests := map[string]struct {
+		req  any
+		want string
+	}{
+		"tf5 ReadResource": {
+			req:  &tfplugin5.ReadResource_Request{TypeName: "test_thing"},
+			want: "test_thing",
+		},
+		"tf6 ReadDataSource": {
+			req:  &tfplugin6.ReadDataSource_Request{TypeName: "test_thing"},
+			want: "test_thing",
+		},
+		"tf5 MoveResourceState": {
+			req: &tfplugin5.MoveResourceState_Request{
+				SourceTypeName: "other_thing",
+				TargetTypeName: "test_thing",
+			},
+			want: "test_thing",
+		},
+		"tf6 MoveResourceState": {
+			req: &tfplugin6.MoveResourceState_Request{
+				SourceTypeName: "other_thing",
+				TargetTypeName: "test_thing",
+			},
+			want: "test_thing",
+		},
+		"tf6 GetProviderSchema": {
+			req:  &tfplugin6.GetProviderSchema_Request{},
+			want: "",
+		},
+		"tf6 CallFunction": {
+			req:  &tfplugin6.CallFunction_Request{Name: "test_func"},
+			want: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := requestResourceType(test.req)
+			if got != test.want {
+				t.Errorf("wrong result %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// connTestProvisioner is a provisioner server whose ProvisionResource sends
+// one message with a warning diagnostic and then either ends the stream
+// successfully or, if err is non-nil, fails with that error.
+type connTestProvisioner struct {
+	tfplugin5.UnimplementedProvisionerServer
+	err error
+}
+
+func (p connTestProvisioner) ProvisionResource(req *tfplugin5.ProvisionResource_Request, stream tfplugin5.Provisioner_ProvisionResourceServer) error {
+	err := stream.Send(&tfplugin5.ProvisionResource_Response{
+		Output: "working",
+		Diagnostics: []*tfplugin5.Diagnostic{
+			{Severity: tfplugin5.Diagnostic_WARNING, Summary: "careful"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return p.err
+}
+
+func TestInterceptedConnStreamTrace(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		wantCode grpcCodes.Code
+	}{
+		"success": {
+			wantCode: grpcCodes.OK,
+		},
+		"failure": {
+			err:      grpcStatus.Error(grpcCodes.PermissionDenied, "not allowed"),
+			wantCode: grpcCodes.PermissionDenied,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := startTestServer(t, nil, connTestProvisioner{err: test.err})
+			plugin := New(conn)
+			client := tfplugin5.NewProvisionerClient(plugin.Conn(conn, nil))
+
+			type startKey struct{}
+			var starts int
+			var ends []*providertrace.OperationResult
+			ctx := providertrace.ContextWithTracer(context.Background(), &providertrace.Tracer{
+				OperationStart: func(ctx context.Context, info *providertrace.OperationInfo) context.Context {
+					starts++
+					if got, want := info.Operation, "ProvisionResource"; got != want {
+						t.Errorf("wrong operation %q at start; want %q", got, want)
+					}
+					return context.WithValue(ctx, startKey{}, true)
+				},
+				OperationEnd: func(ctx context.Context, info *providertrace.OperationInfo, result *providertrace.OperationResult) {
+					if ctx.Value(startKey{}) == nil {
+						t.Error("OperationEnd did not receive the context from OperationStart")
+					}
+					if got, want := info.ProtocolVersion, 5; got != want {
+						t.Errorf("wrong protocol version %d at end; want %d", got, want)
+					}
+					ends = append(ends, result)
+				},
+			})
+
+			stream, err := client.ProvisionResource(ctx, &tfplugin5.ProvisionResource_Request{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if starts != 1 {
+				t.Fatalf("OperationStart called %d times; want 1", starts)
+			}
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+				if len(ends) != 0 {
+					t.Fatal("OperationEnd called before the end of the stream")
+				}
+			}
+			// Reading again after the end must not report the end again.
+			_, _ = stream.Recv()
+
+			if len(ends) != 1 {
+				t.Fatalf("OperationEnd called %d times; want 1", len(ends))
+			}
+			result := ends[0]
+			if result.StatusCode != test.wantCode {
+				t.Errorf("wrong status code %s; want %s", result.StatusCode, test.wantCode)
+			}
+			if (result.Err != nil) != (test.err != nil) {
+				t.Errorf("wrong error %v in result; want error %t", result.Err, test.err != nil)
+			}
+			if result.WarningDiagnostics != 1 || result.ErrorDiagnostics != 0 {
+				t.Errorf("wrong diagnostic counts %d errors, %d warnings; want 0 errors, 1 warning", result.ErrorDiagnostics, result.WarningDiagnostics)
+			}
+		})
+	}
+}
+
+// metadataTestProvider is a provider server whose ReadDataSource sends the
+// gRPC metadata it received to a channel.
+type metadataTestProvider struct {
+	tfplugin6.UnimplementedProviderServer
+	received chan<- metadata.MD
+}
+
+func (p metadataTestProvider) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p.received <- md
+	return &tfplugin6.ReadDataSource_Response{}, nil
+}
+
+func TestInterceptedConnPropagationHeaders(t *testing.T) {
+	received := make(chan metadata.MD, 1)
+	conn := startTestServer(t, metadataTestProvider{received: received}, nil)
+	plugin := New(conn)
+	client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+	type startKey struct{}
+	ctx := providertrace.ContextWithTracer(context.Background(), &providertrace.Tracer{
+		OperationStart: func(ctx context.Context, info *providertrace.OperationInfo) context.Context {
+			return context.WithValue(ctx, startKey{}, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		},
+		PropagationHeaders: func(ctx context.Context) map[string]string {
+			traceparent, _ := ctx.Value(startKey{}).(string)
+			if traceparent == "" {
+				t.Error("PropagationHeaders did not receive the context from OperationStart")
+			}
+			return map[string]string{"Traceparent": traceparent}
+		},
+	})
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-existing", "kept")
+
+	if _, err := client.ReadDataSource(ctx, &tfplugin6.ReadDataSource_Request{TypeName: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	md := <-received
+	if got, want := md.Get("traceparent"), []string{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}; !slices.Equal(got, want) {
+		t.Errorf("wrong traceparent %q; want %q", got, want)
+	}
+	if got, want := md.Get("x-existing"), []string{"kept"}; !slices.Equal(got, want) {
+		t.Errorf("wrong x-existing %q; want %q", got, want)
+	}
+}
+
+// metricsTestProvider is a provider server whose ReadDataSource behaves
+// differently depending on the requested type name.
+type metricsTestProvider struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (metricsTestProvider) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	switch req.TypeName {
+	case "test_diags":
+		return &tfplugin6.ReadDataSource_Response{
+			Diagnostics: []*tfplugin6.Diagnostic{
+				{Severity: tfplugin6.Diagnostic_ERROR, Summary: "broken"},
+			},
+		}, nil
+	case "test_fail":
+		return nil, grpcStatus.Error(grpcCodes.Internal, "failed")
+	case "test_slow":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	default:
+		return &tfplugin6.ReadDataSource_Response{}, nil
+	}
+}
+
+func TestInterceptedConnMetrics(t *testing.T) {
+	tests := map[string]providertrace.Outcome{
+		"test_ok":    providertrace.OutcomeOK,
+		"test_diags": providertrace.OutcomeDiagnosticsError,
+		"test_fail":  providertrace.OutcomeTransportError,
+		"test_slow":  providertrace.OutcomeTransportError,
+	}
+
+	for typeName, wantOutcome := range tests {
+		t.Run(typeName, func(t *testing.T) {
+			conn := startTestServer(t, metricsTestProvider{}, nil)
+			plugin := New(conn)
+			plugin.SetTimeoutPolicy(&providerops.TimeoutPolicy{
+				Timeouts: map[providerops.OperationKind]time.Duration{
+					providerops.OperationRead: 100 * time.Millisecond,
+				},
+			})
+			client := tfplugin6.NewProviderClient(plugin.Conn(conn, nil))
+
+			type measurement struct {
+				name   string
+				labels providertrace.MetricLabels
+				value  float64
+			}
+			var counters, histograms []measurement
+			ctx := providertrace.ContextWithTracer(context.Background(), &providertrace.Tracer{
+				Metrics: &providertrace.Metrics{
+					Counter: func(name string, labels providertrace.MetricLabels, delta float64) {
+						counters = append(counters, measurement{name, labels, delta})
+					},
+					Histogram: func(name string, labels providertrace.MetricLabels, value float64) {
+						histograms = append(histograms, measurement{name, labels, value})
+					},
+				},
+			})
+
+			start := time.Now()
+			_, _ = client.ReadDataSource(ctx, &tfplugin6.ReadDataSource_Request{TypeName: typeName})
+			elapsed := time.Since(start)
+
+			wantLabels := providertrace.MetricLabels{
+				ProtocolVersion: 6,
+				Operation:       "ReadDataResource",
+				ResourceType:    typeName,
+				Outcome:         wantOutcome,
+			}
+			if len(counters) != 1 {
+				t.Fatalf("Counter called %d times; want 1", len(counters))
+			}
+			if got, want := counters[0], (measurement{providertrace.MetricOperations, wantLabels, 1}); got != want {
+				t.Errorf("wrong counter measurement\ngot:  %#v\nwant: %#v", got, want)
+			}
+			if len(histograms) != 1 {
+				t.Fatalf("Histogram called %d times; want 1", len(histograms))
+			}
+			got := histograms[0]
+			if got.name != providertrace.MetricOperationDuration {
+				t.Errorf("wrong histogram name %q; want %q", got.name, providertrace.MetricOperationDuration)
+			}
+			if got.labels != wantLabels {
+				t.Errorf("wrong histogram labels\ngot:  %#v\nwant: %#v", got.labels, wantLabels)
+			}
+			if got.value <= 0 || got.value > elapsed.Seconds() {
+				t.Errorf("wrong duration %gs; want between 0s and %gs", got.value, elapsed.Seconds())
+			}
+		})
+	}
+}
+
+func TestInterceptedConnStreamMetrics(t *testing.T) {
+	conn := startTestServer(t, nil, connTestProvisioner{})
+	plugin := New(conn)
+	client := tfplugin5.NewProvisionerClient(plugin.Conn(conn, nil))
+
+	var labels []providertrace.MetricLabels
+	ctx := providertrace.ContextWithTracer(context.Background(), &providertrace.Tracer{
+		Metrics: &providertrace.Metrics{
+			Counter: func(name string, l providertrace.MetricLabels, delta float64) {
+				labels = append(labels, l)
+			},
+		},
+	})
+	stream, err := client.ProvisionResource(ctx, &tfplugin5.ProvisionResource_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	want := []providertrace.MetricLabels{
+		{
+			ProtocolVersion: 5,
+			Operation:       "ProvisionResource",
+			Outcome:         providertrace.OutcomeOK,
+		},
+	}
+	if !slices.Equal(labels, want) {
+		t.Errorf("wrong counter labels\ngot:  %#v\nwant: %#v", labels, want)
+	}
+}
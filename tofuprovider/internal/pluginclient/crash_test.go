@@ -0,0 +1,125 @@
+package pluginclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+func TestPluginCrash(t *testing.T) {
+	cmd := helperCommand(t, "crash")
+	stderr := NewStderrTail(nil, nil)
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	plugin := newOwnedChildProcess(nil, cmd, stderr)
+	defer plugin.Close()
+
+	assertCrashed(t, plugin)
+}
+
+// assertCrashed checks that the given plugin, whose child process is running
+// the "crash" helper behavior, notices that the process has exited and then
+// reports a request failing with Unavailable as a crash.
+func assertCrashed(t *testing.T, plugin *Plugin) {
+	t.Helper()
+
+	select {
+	case <-plugin.Exited():
+	case <-time.After(10 * time.Second):
+		t.Fatal("plugin did not exit")
+	}
+	exitCode, exited := plugin.ExitStatus()
+	if !exited {
+		t.Fatal("ExitStatus reports that the plugin has not exited")
+	}
+	if exitCode != helperExitCode {
+		t.Errorf("wrong exit code %d; want %d", exitCode, helperExitCode)
+	}
+
+	unavailable := grpcStatus.Error(grpcCodes.Unavailable, "connection refused")
+	conn := plugin.Conn(fakeConn(func(ctx context.Context, method string) error {
+		return unavailable
+	}), nil)
+	client := tfplugin6.NewProviderClient(conn)
+	_, err := client.ReadDataSource(context.Background(), &tfplugin6.ReadDataSource_Request{})
+
+	var crashErr providerops.ProviderCrashedError
+	if !errors.As(err, &crashErr) {
+		t.Fatalf("wrong error type %T; want providerops.ProviderCrashedError\n%s", err, err)
+	}
+	if crashErr.ExitCode != helperExitCode {
+		t.Errorf("wrong ExitCode %d; want %d", crashErr.ExitCode, helperExitCode)
+	}
+	if !strings.Contains(crashErr.StderrTail, strings.TrimSpace(helperStderr)) {
+		t.Errorf("StderrTail does not include the helper's output\ngot: %q", crashErr.StderrTail)
+	}
+	if !errors.Is(err, unavailable) {
+		t.Errorf("ProviderCrashedError does not wrap the original error")
+	}
+}
+
+func TestPluginUnavailableWhileRunning(t *testing.T) {
+	cmd := helperCommand(t, "sleep")
+	stderr := NewStderrTail(nil, nil)
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	plugin := NewChildProcess(nil, cmd.Process, stderr)
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	unavailable := grpcStatus.Error(grpcCodes.Unavailable, "connection reset")
+	conn := plugin.Conn(fakeConn(func(ctx context.Context, method string) error {
+		return unavailable
+	}), nil)
+	client := tfplugin6.NewProviderClient(conn)
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := map[string]struct {
+		ctx        context.Context
+		maxElapsed time.Duration
+	}{
+		// A plugin that's still running might return Unavailable for
+		// reasons other than crashing, so we only briefly wait to see
+		// whether it exits.
+		"running": {
+			ctx:        context.Background(),
+			maxElapsed: crashWaitTimeout + time.Second,
+		},
+		// If the caller has given up on the request then we don't wait
+		// at all.
+		"canceled": {
+			ctx:        canceledCtx,
+			maxElapsed: crashWaitTimeout,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			_, err := client.ReadDataSource(test.ctx, &tfplugin6.ReadDataSource_Request{})
+			if elapsed := time.Since(start); elapsed >= test.maxElapsed {
+				t.Errorf("request returned after %s; want less than %s", elapsed, test.maxElapsed)
+			}
+			if err != unavailable {
+				t.Errorf("wrong error\ngot:  %#v\nwant: %#v", err, unavailable)
+			}
+		})
+	}
+	if _, exited := plugin.ExitStatus(); exited {
+		t.Error("ExitStatus reports that the plugin has exited")
+	}
+}
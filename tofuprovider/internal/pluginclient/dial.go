@@ -0,0 +1,26 @@
+package pluginclient
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Dial returns a gRPC client connection to a plugin listening at the given
+// address, using the given transport credentials.
+func Dial(addr net.Addr, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
+	// The target address given to grpc.NewClient is not actually used,
+	// because our custom dialer always connects to the given addr. We do
+	// it this way because gRPC's own target syntax cannot represent all
+	// of the possible network types that net.Addr can describe.
+	return grpc.NewClient(
+		"passthrough:///localhost",
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, addr.Network(), addr.String())
+		}),
+	)
+}
@@ -0,0 +1,5 @@
+// Package pluginclient contains the parts of the plugin client that are
+// shared across all plugin types and protocol major versions, such as
+// tracking the lifecycle of a plugin's child process and intercepting
+// the requests sent to it.
+package pluginclient
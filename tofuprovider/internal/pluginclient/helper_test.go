@@ -0,0 +1,102 @@
+package pluginclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+)
+
+// helperEnv is the environment variable that tells [TestHelperProcess] to
+// behave as a child process for another test, and which behavior to use.
+const helperEnv = "PLUGINCLIENT_TEST_HELPER"
+
+// helperExitCode is the exit code used by the "crash" helper behavior.
+const helperExitCode = 3
+
+// helperStderr is what the "crash" helper behavior writes to stderr.
+const helperStderr = "panic: helper process crashed\n"
+
+// TestHelperProcess is not a real test. It's run in a child process by other
+// tests, using [helperCommand], to stand in for a plugin.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperEnv) {
+	case "":
+		return // running as a normal test
+	case "crash":
+		fmt.Fprint(os.Stderr, helperStderr)
+		os.Exit(helperExitCode)
+	case "sleep":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "automtls", "automtls-wrong-cert", "automtls-orphan":
+		runAutoMTLSHelper(os.Getenv(helperEnv))
+	case "exit-on-stdin", "ignore-sigterm":
+		runShutdownHelper(os.Getenv(helperEnv))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown helper behavior %q\n", os.Getenv(helperEnv))
+		os.Exit(1)
+	}
+}
+
+// helperCommand returns a command that runs the test binary as a child
+// process with the given [TestHelperProcess] behavior.
+func helperCommand(t *testing.T, behavior string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+behavior)
+	return cmd
+}
+
+// fakeConn is a [grpc.ClientConnInterface] that handles all requests by
+// calling a function, for tests that don't need a real connection.
+type fakeConn func(ctx context.Context, method string) error
+
+func (c fakeConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return c(ctx, method)
+}
+
+func (c fakeConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, c(ctx, method)
+}
+
+// startTestServer starts an in-process gRPC server offering the given
+// services, any of which may be nil, and returns a connection to it. The
+// server and connection are stopped when the test completes.
+func startTestServer(t *testing.T, provider tfplugin6.ProviderServer, provisioner tfplugin5.ProvisionerServer) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	if provider != nil {
+		tfplugin6.RegisterProviderServer(srv, provider)
+	}
+	if provisioner != nil {
+		tfplugin5.RegisterProvisionerServer(srv, provisioner)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,141 @@
+package pluginclient
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+// operation describes one of the operations that a client can request from
+// a plugin, in terms of the public API of this module rather than the
+// underlying gRPC method.
+type operation struct {
+	// name is the name of the method of the public Provider or Provisioner
+	// interface that makes this request.
+	name string
+
+	// kind is the kind of operation this is for the purposes of
+	// [providerops.TimeoutPolicy], or zero if operations of this type are
+	// not subject to timeout policies.
+	kind providerops.OperationKind
+}
+
+// operations maps from the full gRPC method names used in each of the
+// plugin protocols to the operations they represent.
+var operations = map[string]operation{
+	"/tfplugin5.Provider/GetMetadata":                     {"GetMetadata", providerops.OperationSchema},
+	"/tfplugin5.Provider/GetSchema":                       {"GetProviderSchema", providerops.OperationSchema},
+	"/tfplugin5.Provider/GetResourceIdentitySchemas":      {"GetResourceIdentitySchemas", providerops.OperationSchema},
+	"/tfplugin5.Provider/PrepareProviderConfig":           {"ValidateProviderConfig", providerops.OperationValidate},
+	"/tfplugin5.Provider/ValidateResourceTypeConfig":      {"ValidateManagedResourceConfig", providerops.OperationValidate},
+	"/tfplugin5.Provider/ValidateDataSourceConfig":        {"ValidateDataResourceConfig", providerops.OperationValidate},
+	"/tfplugin5.Provider/UpgradeResourceState":            {"UpgradeManagedResourceState", providerops.OperationRead},
+	"/tfplugin5.Provider/UpgradeResourceIdentity":         {"UpgradeManagedResourceIdentity", providerops.OperationRead},
+	"/tfplugin5.Provider/Configure":                       {"ConfigureProvider", providerops.OperationConfigure},
+	"/tfplugin5.Provider/ReadResource":                    {"ReadManagedResource", providerops.OperationRead},
+	"/tfplugin5.Provider/PlanResourceChange":              {"PlanManagedResourceChange", providerops.OperationPlan},
+	"/tfplugin5.Provider/ApplyResourceChange":             {"ApplyManagedResourceChange", providerops.OperationApply},
+	"/tfplugin5.Provider/ImportResourceState":             {"ImportManagedResourceState", providerops.OperationRead},
+	"/tfplugin5.Provider/MoveResourceState":               {"MoveManagedResourceState", providerops.OperationRead},
+	"/tfplugin5.Provider/ReadDataSource":                  {"ReadDataResource", providerops.OperationRead},
+	"/tfplugin5.Provider/ValidateEphemeralResourceConfig": {"ValidateEphemeralResourceConfig", providerops.OperationValidate},
+	"/tfplugin5.Provider/OpenEphemeralResource":           {"OpenEphemeralResource", providerops.OperationRead},
+	"/tfplugin5.Provider/RenewEphemeralResource":          {"RenewEphemeralResource", providerops.OperationRead},
+	"/tfplugin5.Provider/CloseEphemeralResource":          {"CloseEphemeralResource", providerops.OperationRead},
+	"/tfplugin5.Provider/GetFunctions":                    {"GetFunctions", providerops.OperationSchema},
+	"/tfplugin5.Provider/CallFunction":                    {"CallFunction", providerops.OperationFunction},
+	"/tfplugin5.Provider/Stop":                            {"GracefulStop", 0},
+
+	"/tfplugin5.Provisioner/GetSchema":                 {"GetSchema", providerops.OperationSchema},
+	"/tfplugin5.Provisioner/ValidateProvisionerConfig": {"ValidateConfig", providerops.OperationValidate},
+	"/tfplugin5.Provisioner/ProvisionResource":         {"ProvisionResource", 0},
+	"/tfplugin5.Provisioner/Stop":                      {"GracefulStop", 0},
+
+	"/tfplugin6.Provider/GetMetadata":                     {"GetMetadata", providerops.OperationSchema},
+	"/tfplugin6.Provider/GetProviderSchema":               {"GetProviderSchema", providerops.OperationSchema},
+	"/tfplugin6.Provider/GetResourceIdentitySchemas":      {"GetResourceIdentitySchemas", providerops.OperationSchema},
+	"/tfplugin6.Provider/ValidateProviderConfig":          {"ValidateProviderConfig", providerops.OperationValidate},
+	"/tfplugin6.Provider/ValidateResourceConfig":          {"ValidateManagedResourceConfig", providerops.OperationValidate},
+	"/tfplugin6.Provider/ValidateDataResourceConfig":      {"ValidateDataResourceConfig", providerops.OperationValidate},
+	"/tfplugin6.Provider/UpgradeResourceState":            {"UpgradeManagedResourceState", providerops.OperationRead},
+	"/tfplugin6.Provider/UpgradeResourceIdentity":         {"UpgradeManagedResourceIdentity", providerops.OperationRead},
+	"/tfplugin6.Provider/ConfigureProvider":               {"ConfigureProvider", providerops.OperationConfigure},
+	"/tfplugin6.Provider/ReadResource":                    {"ReadManagedResource", providerops.OperationRead},
+	"/tfplugin6.Provider/PlanResourceChange":              {"PlanManagedResourceChange", providerops.OperationPlan},
+	"/tfplugin6.Provider/ApplyResourceChange":             {"ApplyManagedResourceChange", providerops.OperationApply},
+	"/tfplugin6.Provider/ImportResourceState":             {"ImportManagedResourceState", providerops.OperationRead},
+	"/tfplugin6.Provider/MoveResourceState":               {"MoveManagedResourceState", providerops.OperationRead},
+	"/tfplugin6.Provider/ReadDataSource":                  {"ReadDataResource", providerops.OperationRead},
+	"/tfplugin6.Provider/ValidateEphemeralResourceConfig": {"ValidateEphemeralResourceConfig", providerops.OperationValidate},
+	"/tfplugin6.Provider/OpenEphemeralResource":           {"OpenEphemeralResource", providerops.OperationRead},
+	"/tfplugin6.Provider/RenewEphemeralResource":          {"RenewEphemeralResource", providerops.OperationRead},
+	"/tfplugin6.Provider/CloseEphemeralResource":          {"CloseEphemeralResource", providerops.OperationRead},
+	"/tfplugin6.Provider/GetFunctions":                    {"GetFunctions", providerops.OperationSchema},
+	"/tfplugin6.Provider/CallFunction":                    {"CallFunction", providerops.OperationFunction},
+	"/tfplugin6.Provider/StopProvider":                    {"GracefulStop", 0},
+}
+
+// lookupOperation returns the operation for the given full gRPC method name.
+//
+// If the method is not one we know about then the result uses the gRPC
+// method name as the operation name and is not subject to timeout policies.
+func lookupOperation(method string) operation {
+	if op, ok := operations[method]; ok {
+		return op
+	}
+	return operation{name: method}
+}
+
+// methodProtocolVersion returns the major version of the protocol that the
+// given full gRPC method name belongs to, or zero if it's not a method from
+// one of the plugin protocols.
+func methodProtocolVersion(method string) int {
+	switch {
+	case strings.HasPrefix(method, "/tfplugin5."):
+		return 5
+	case strings.HasPrefix(method, "/tfplugin6."):
+		return 6
+	default:
+		return 0
+	}
+}
+
+// countDiagnostics returns the number of error and warning diagnostics in
+// the given response message, which can be from any of the plugin protocols.
+//
+// The result is zero for both if the message has no diagnostics field.
+func countDiagnostics(reply any) (errs, warnings int) {
+	msg, ok := reply.(proto.Message)
+	if !ok {
+		return 0, 0
+	}
+	refl := msg.ProtoReflect()
+	field := refl.Descriptor().Fields().ByName("diagnostics")
+	if field == nil || !field.IsList() || field.Message() == nil {
+		return 0, 0
+	}
+	severityField := field.Message().Fields().ByName("severity")
+	if severityField == nil {
+		return 0, 0
+	}
+	list := refl.Get(field).List()
+	for i := range list.Len() {
+		// All of the protocol versions use the same numbering for
+		// the severity enumeration.
+		switch list.Get(i).Message().Get(severityField).Enum() {
+		case diagnosticSeverityError:
+			errs++
+		case diagnosticSeverityWarning:
+			warnings++
+		}
+	}
+	return errs, warnings
+}
+
+const (
+	diagnosticSeverityError   protoreflect.EnumNumber = 1
+	diagnosticSeverityWarning protoreflect.EnumNumber = 2
+)
@@ -0,0 +1,287 @@
+package pluginclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+// ErrClosed is the error returned by requests to a plugin that has been
+// closed, including requests that were canceled because the plugin was
+// closed while they were in progress.
+var ErrClosed = errors.New("provider has been closed")
+
+// Plugin represents the client's side of a connection to a plugin, which
+// might be running either as a child process of the current process or
+// somewhere else entirely.
+//
+// The zero value is not a valid Plugin. Use either [New] or
+// [NewChildProcess] to create one.
+type Plugin struct {
+	// closer is closed when the plugin is closed, or is nil if there's
+	// nothing to close.
+	closer    io.Closer
+	closeOnce sync.Once
+	closeErr  error
+
+	// terminating is set once the client has begun intentionally
+	// terminating the plugin, after which failed requests are not
+	// considered to be crashes.
+	terminating atomic.Bool
+
+	// process is the plugin's child process, or nil if the plugin is not
+	// running as a child process.
+	process *os.Process
+
+	// exited is closed once the plugin's child process has exited, or is
+	// nil if the plugin is not running as a child process.
+	exited     chan struct{}
+	exitedOnce sync.Once
+	exitCode   int // only valid once exited is closed
+
+	// exitMonitored is true if exited is closed as soon as the child process
+	// exits, or false if it's closed only once the plugin is closed.
+	exitMonitored bool
+
+	// stderr captures the most recent data the child process wrote to its
+	// stderr stream, or is nil if the plugin is not running as a child
+	// process.
+	stderr *StderrTail
+
+	// timeoutPolicy is the default timeout policy for requests to this
+	// plugin, or nil if there is no default policy.
+	timeoutPolicy *providerops.TimeoutPolicy
+
+	// recorder, if non-nil, records all requests made to this plugin.
+	recorder *Recorder
+
+	// closeCtx is canceled when the plugin is closed, to cancel any
+	// requests that are still in progress.
+	closeCtx    context.Context
+	cancelClose context.CancelFunc
+
+	// mu guards the fields below it.
+	mu sync.Mutex
+
+	// closed is set once [Plugin.Close] has been called, after which no
+	// new requests may begin.
+	closed bool
+
+	// inflight is the number of requests currently in progress.
+	inflight int
+
+	// idle, if non-nil, is closed once inflight next reaches zero.
+	idle chan struct{}
+}
+
+// New returns a [Plugin] that is not running as a child process of the
+// current process, and so there's no process for the client to manage.
+//
+// If closer is non-nil then it's closed when the plugin is closed. This is
+// typically a *grpc.ClientConn that the client has exclusive use of.
+func New(closer io.Closer) *Plugin {
+	p := &Plugin{closer: closer}
+	p.closeCtx, p.cancelClose = context.WithCancel(context.Background())
+	return p
+}
+
+// NewChildProcess returns a [Plugin] representing the already-started child
+// process with the given process object.
+//
+// closer is closed when the plugin is closed, and is expected to terminate
+// the child process. stderr must be the object that the child process's
+// stderr stream is being written to.
+func NewChildProcess(closer io.Closer, process *os.Process, stderr *StderrTail) *Plugin {
+	p := newChildProcess(closer, process, stderr)
+	if canWaitExited {
+		p.exitMonitored = true
+		go p.waitExited(process.Pid)
+	}
+	// Otherwise we'll only notice that the process has exited once the
+	// plugin is closed.
+	return p
+}
+
+// newOwnedChildProcess is like [NewChildProcess] but for a child process
+// that this package started itself, and so it's our responsibility to wait
+// for the process to exit.
+func newOwnedChildProcess(closer io.Closer, cmd *exec.Cmd, stderr *StderrTail) *Plugin {
+	p := newChildProcess(closer, cmd.Process, stderr)
+	p.exitMonitored = true
+	go func() {
+		_ = cmd.Wait() // we only care about the exit code
+		p.markExited(cmd.ProcessState.ExitCode())
+	}()
+	return p
+}
+
+func newChildProcess(closer io.Closer, process *os.Process, stderr *StderrTail) *Plugin {
+	p := &Plugin{
+		closer:  closer,
+		process: process,
+		exited:  make(chan struct{}),
+		stderr:  stderr,
+	}
+	p.closeCtx, p.cancelClose = context.WithCancel(context.Background())
+	return p
+}
+
+// SetTimeoutPolicy sets the default timeout policy for requests to this
+// plugin, which is used for any request whose context does not have its own
+// policy set using [providerops.ContextWithTimeoutPolicy].
+//
+// This must be called before making any requests to the plugin.
+func (p *Plugin) SetTimeoutPolicy(policy *providerops.TimeoutPolicy) {
+	p.timeoutPolicy = policy
+}
+
+// SetRecorder sets a recorder to record all of the requests made to this
+// plugin, and the plugin's responses. Any error writing the recording is
+// returned by [Plugin.Close].
+//
+// Recordings cannot include streaming requests, so while a recorder is set
+// any streaming request fails without being sent to the plugin.
+//
+// This must be called before making any requests to the plugin.
+func (p *Plugin) SetRecorder(recorder *Recorder) {
+	p.recorder = recorder
+}
+
+// Close closes the connection to the plugin and terminates its child process,
+// if any.
+//
+// Any requests still in progress are canceled, and Close waits for them to
+// return before closing the connection. Requests that were in progress and
+// any requests made after Close has been called fail with [ErrClosed].
+//
+// It's okay to call Close multiple times, including concurrently.
+// Subsequent calls wait for the first call to complete and then return the
+// same error.
+func (p *Plugin) Close() error {
+	p.closeOnce.Do(func() {
+		p.terminating.Store(true)
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		p.cancelClose()
+		<-p.idleCh()
+
+		if p.closer != nil {
+			p.closeErr = p.closer.Close()
+		}
+		// The plugin won't write anything more to stderr once it's been
+		// closed, so any final incomplete log line can be flushed.
+		_ = p.stderr.Close()
+		if p.exited != nil {
+			// If we've not already noticed the child process exiting then
+			// we'll assume that closing it has terminated it, even though
+			// we don't know its exit code.
+			p.markExited(-1)
+		}
+		if err := p.recorder.Err(); err != nil {
+			p.closeErr = errors.Join(p.closeErr, fmt.Errorf("failed to record requests: %w", err))
+		}
+	})
+	return p.closeErr
+}
+
+// Exited returns a channel that is closed once the plugin's child process
+// has exited.
+//
+// If the plugin is not running as a child process then the result is a nil
+// channel, which is never closed.
+func (p *Plugin) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// ExitStatus returns the exit code of the plugin's child process and true
+// if it has exited, or false if it is still running or if the plugin is not
+// running as a child process.
+//
+// The exit code is -1 if the process was terminated by a signal, or if it
+// exited in a way that did not allow the exit code to be determined.
+func (p *Plugin) ExitStatus() (int, bool) {
+	if p.exited == nil {
+		return 0, false
+	}
+	select {
+	case <-p.exited:
+		return p.exitCode, true
+	default:
+		return 0, false
+	}
+}
+
+// isTerminating returns true if the client has begun intentionally
+// terminating the plugin, using either [Plugin.Close] or [Plugin.Shutdown].
+func (p *Plugin) isTerminating() bool {
+	return p.terminating.Load()
+}
+
+// beginRequest records that a new request to the plugin is starting, or
+// returns [ErrClosed] if the plugin has been closed. Each successful call
+// must be followed by exactly one call to endRequest.
+func (p *Plugin) beginRequest() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	p.inflight++
+	return nil
+}
+
+// endRequest records that a request previously recorded by beginRequest
+// has finished.
+func (p *Plugin) endRequest() {
+	p.mu.Lock()
+	p.inflight--
+	if p.inflight == 0 && p.idle != nil {
+		close(p.idle)
+		p.idle = nil
+	}
+	p.mu.Unlock()
+}
+
+// idleCh returns a channel that is closed once there are no requests in
+// progress, which might be immediately.
+func (p *Plugin) idleCh() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inflight == 0 {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if p.idle == nil {
+		p.idle = make(chan struct{})
+	}
+	return p.idle
+}
+
+// waitExited blocks until the child process with the given pid has exited
+// and then records that it has exited.
+func (p *Plugin) waitExited(pid int) {
+	exitCode, err := waitExited(pid)
+	if err != nil {
+		// Some other process has probably already reaped the child
+		// process, so we know that it has exited but we can't know
+		// its exit code.
+		exitCode = -1
+	}
+	p.markExited(exitCode)
+}
+
+func (p *Plugin) markExited(exitCode int) {
+	p.exitedOnce.Do(func() {
+		p.exitCode = exitCode
+		close(p.exited)
+	})
+}
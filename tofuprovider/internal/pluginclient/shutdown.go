@@ -0,0 +1,120 @@
+package pluginclient
+
+import (
+	"context"
+	"syscall"
+	"time"
+)
+
+// ShutdownStage describes which stage of [Plugin.Shutdown] caused the plugin
+// to terminate.
+type ShutdownStage int
+
+const (
+	// ShutdownExited means that the plugin's child process exited without
+	// being sent any signals, such as because it exited by itself after
+	// being asked to stop gracefully.
+	ShutdownExited ShutdownStage = iota + 1
+
+	// ShutdownTerminated means that the plugin's child process exited after
+	// being sent SIGTERM.
+	ShutdownTerminated
+
+	// ShutdownKilled means that the plugin's child process did not exit
+	// within the grace period after being sent SIGTERM, or could not be sent
+	// SIGTERM at all, and so it was sent SIGKILL.
+	ShutdownKilled
+
+	// ShutdownDisconnected means that the plugin is not running as a child
+	// process, and so shutting it down only closed the connection to it.
+	ShutdownDisconnected
+
+	// ShutdownUnconfirmed means that the plugin's child process was sent
+	// SIGKILL after both grace periods because [Plugin.Exited] cannot
+	// detect it exiting, and so it's unknown which stage terminated it.
+	ShutdownUnconfirmed
+)
+
+func (s ShutdownStage) String() string {
+	switch s {
+	case ShutdownExited:
+		return "exited"
+	case ShutdownTerminated:
+		return "terminated"
+	case ShutdownKilled:
+		return "killed"
+	case ShutdownDisconnected:
+		return "disconnected"
+	case ShutdownUnconfirmed:
+		return "unconfirmed"
+	default:
+		return "unknown"
+	}
+}
+
+// Shutdown terminates the plugin in a series of increasingly-forceful
+// stages, and then closes it in the same way as [Plugin.Close].
+//
+// First gracefulStop is called to ask the plugin to stop any operations in
+// progress, and then Shutdown waits up to gracePeriod for all in-progress
+// requests to finish. It then sends SIGTERM to the plugin's child process
+// and waits up to gracePeriod again for it to exit, before finally sending
+// SIGKILL. If ctx is canceled then Shutdown skips directly to sending
+// SIGKILL.
+//
+// The result describes which of those stages caused the plugin to terminate.
+// If [Plugin.Exited] cannot detect the process exiting then Shutdown always
+// escalates to SIGKILL after waiting for both grace periods, and the result
+// is [ShutdownUnconfirmed].
+func (p *Plugin) Shutdown(ctx context.Context, gracePeriod time.Duration, gracefulStop func(context.Context) error) (ShutdownStage, error) {
+	p.terminating.Store(true)
+	stage := p.shutdownStages(ctx, gracePeriod, gracefulStop)
+	return stage, p.Close()
+}
+
+func (p *Plugin) shutdownStages(ctx context.Context, gracePeriod time.Duration, gracefulStop func(context.Context) error) ShutdownStage {
+	if _, exited := p.ExitStatus(); exited {
+		return ShutdownExited // nothing to stop
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, gracePeriod)
+	defer cancel()
+	// We intentionally ignore any error from gracefulStop because we're
+	// going to escalate to more forceful measures if it isn't effective
+	// anyway.
+	_ = gracefulStop(stopCtx)
+	select {
+	case <-p.idleCh():
+	case <-p.exited:
+		return ShutdownExited
+	case <-stopCtx.Done():
+	}
+
+	if p.process == nil {
+		return ShutdownDisconnected
+	}
+	if _, exited := p.ExitStatus(); exited {
+		return ShutdownExited
+	}
+
+	// Sending SIGTERM fails on platforms that don't support it, such as
+	// Windows, in which case we'll skip directly to SIGKILL.
+	if ctx.Err() == nil && p.process.Signal(syscall.SIGTERM) == nil {
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case <-p.exited:
+			return ShutdownTerminated
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	_ = p.process.Kill()
+	if !p.exitMonitored {
+		// The process might have exited at any earlier stage without us
+		// noticing, so we can't claim that it was killed.
+		return ShutdownUnconfirmed
+	}
+	return ShutdownKilled
+}
@@ -0,0 +1,182 @@
+package pluginclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// shutdownTestGracePeriod is the grace period used for each stage of
+// [Plugin.Shutdown] in these tests.
+const shutdownTestGracePeriod = 200 * time.Millisecond
+
+func TestPluginShutdown(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := map[string]struct {
+		// behavior is the helper behavior to run as the plugin.
+		behavior string
+
+		// exitOnStop makes the gracefulStop function given to Shutdown
+		// close the plugin's stdin, which makes the "exit-on-stdin"
+		// behavior exit, and then wait for it to exit.
+		exitOnStop bool
+
+		ctx         context.Context
+		needSigterm bool
+		want        ShutdownStage
+	}{
+		"exits on GracefulStop": {
+			behavior:   "exit-on-stdin",
+			exitOnStop: true,
+			ctx:        context.Background(),
+			want:       ShutdownExited,
+		},
+		"exits on SIGTERM": {
+			behavior:    "exit-on-stdin",
+			ctx:         context.Background(),
+			needSigterm: true,
+			want:        ShutdownTerminated,
+		},
+		"ignores SIGTERM": {
+			behavior: "ignore-sigterm",
+			ctx:      context.Background(),
+			want:     ShutdownKilled,
+		},
+		"context canceled": {
+			behavior: "exit-on-stdin",
+			ctx:      canceledCtx,
+			want:     ShutdownKilled,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.needSigterm && runtime.GOOS == "windows" {
+				t.Skip("processes cannot be sent SIGTERM on Windows")
+			}
+			cmd, stdin := startShutdownHelper(t, test.behavior)
+			plugin := newOwnedChildProcess(nil, cmd, NewStderrTail(nil, nil))
+
+			stopCalled := false
+			gracefulStop := func(ctx context.Context) error {
+				stopCalled = true
+				if test.exitOnStop {
+					stdin.Close()
+					<-plugin.Exited()
+				}
+				return nil
+			}
+			got, err := plugin.Shutdown(test.ctx, shutdownTestGracePeriod, gracefulStop)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("wrong stage %s; want %s", got, test.want)
+			}
+			if !stopCalled {
+				t.Error("gracefulStop was not called")
+			}
+			if _, exited := plugin.ExitStatus(); !exited {
+				t.Error("ExitStatus reports that the plugin has not exited")
+			}
+		})
+	}
+}
+
+func TestPluginShutdownUnmonitored(t *testing.T) {
+	// This simulates a platform where waitExited is unsupported, so the
+	// client can't tell that the plugin exited as soon as it was asked to.
+	cmd, stdin := startShutdownHelper(t, "exit-on-stdin")
+	plugin := newChildProcess(nil, cmd.Process, NewStderrTail(nil, nil))
+	defer cmd.Wait()
+
+	gracefulStop := func(ctx context.Context) error {
+		return stdin.Close()
+	}
+	got, err := plugin.Shutdown(context.Background(), shutdownTestGracePeriod, gracefulStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ShutdownUnconfirmed {
+		t.Errorf("wrong stage %s; want %s", got, ShutdownUnconfirmed)
+	}
+}
+
+func TestPluginShutdownDisconnected(t *testing.T) {
+	closed := false
+	plugin := New(closerFunc(func() error {
+		closed = true
+		return nil
+	}))
+	stopCalled := false
+	gracefulStop := func(ctx context.Context) error {
+		stopCalled = true
+		return nil
+	}
+
+	got, err := plugin.Shutdown(context.Background(), shutdownTestGracePeriod, gracefulStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ShutdownDisconnected {
+		t.Errorf("wrong stage %s; want %s", got, ShutdownDisconnected)
+	}
+	if !stopCalled {
+		t.Error("gracefulStop was not called")
+	}
+	if !closed {
+		t.Error("connection was not closed")
+	}
+}
+
+// startShutdownHelper starts the given shutdown helper behavior in a child
+// process, returning once it's ready along with a writer for its stdin.
+func startShutdownHelper(t *testing.T, behavior string) (*exec.Cmd, io.WriteCloser) {
+	t.Helper()
+	cmd := helperCommand(t, behavior)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+	})
+	// The helper writes a line once it's ready to be signaled.
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("helper did not become ready: %s", err)
+	}
+	return cmd, stdin
+}
+
+// runShutdownHelper implements the helper behaviors used to test
+// [Plugin.Shutdown], running in a child process.
+//
+// "exit-on-stdin" exits once its stdin is closed, while "ignore-sigterm"
+// ignores SIGTERM and so can only be killed.
+func runShutdownHelper(behavior string) {
+	if behavior == "ignore-sigterm" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+	os.Stdout.WriteString("ready\n")
+	if behavior == "exit-on-stdin" {
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		os.Exit(0)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
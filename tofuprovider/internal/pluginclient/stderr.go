@@ -0,0 +1,79 @@
+package pluginclient
+
+import (
+	"io"
+	"sync"
+)
+
+// stderrTailSize is the maximum number of bytes of stderr output retained
+// by a [StderrTail].
+const stderrTailSize = 16 * 1024
+
+// StderrTail is an [io.Writer] that retains only the most recent data
+// written to it, so that it can be included in error messages if a plugin's
+// child process exits unexpectedly.
+//
+// A StderrTail is safe for concurrent use.
+type StderrTail struct {
+	// next is the writer to pass all written data along to, or nil if
+	// written data should only be retained in buf.
+	next io.Writer
+
+	// logs, if non-nil, also receives all written data, and is closed when
+	// the StderrTail is closed.
+	logs io.WriteCloser
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewStderrTail returns a [StderrTail] that also writes all of the data
+// written to it to next and logs, for each of them that is non-nil.
+//
+// logs is closed when the StderrTail is closed, but next is not.
+func NewStderrTail(next io.Writer, logs io.WriteCloser) *StderrTail {
+	return &StderrTail{next: next, logs: logs}
+}
+
+// Write implements io.Writer.
+func (t *StderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	t.buf = append(t.buf, p...)
+	if excess := len(t.buf) - stderrTailSize; excess > 0 {
+		t.buf = append(t.buf[:0], t.buf[excess:]...)
+	}
+	t.mu.Unlock()
+
+	if t.logs != nil {
+		// Failing to parse log messages is not a good reason to block
+		// the plugin from writing to stderr, so we ignore errors here.
+		_, _ = t.logs.Write(p)
+	}
+	if t.next == nil {
+		return len(p), nil
+	}
+	return t.next.Write(p)
+}
+
+// Close closes the log writer given to [NewStderrTail], if any, once no
+// more data will be written.
+//
+// Close can be called on a nil *StderrTail, doing nothing.
+func (t *StderrTail) Close() error {
+	if t == nil || t.logs == nil {
+		return nil
+	}
+	return t.logs.Close()
+}
+
+// String returns the retained data.
+//
+// String can be called on a nil *StderrTail, returning an empty string.
+func (t *StderrTail) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
@@ -0,0 +1,58 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package pluginclient
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// canWaitExited is true on platforms where [waitExited] is supported.
+const canWaitExited = true
+
+// noteExitStatus is the macOS NOTE_EXITSTATUS flag, which asks for the
+// process's wait status in the event data. The other BSDs always include
+// the wait status with NOTE_EXIT, and don't define this flag.
+const noteExitStatus = 0x04000000
+
+// waitExited blocks until the child process with the given pid has exited,
+// and then returns its exit code or -1 if it was terminated by a signal.
+//
+// This uses a kqueue process filter rather than waiting for the process,
+// so that the process remains waitable, because the process is owned by the
+// rpcplugin library that launched it and so it's not our responsibility to
+// reap it.
+func waitExited(pid int) (int, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return -1, err
+	}
+	defer syscall.Close(kq)
+
+	var change syscall.Kevent_t
+	syscall.SetKevent(&change, pid, syscall.EVFILT_PROC, syscall.EV_ADD|syscall.EV_ONESHOT)
+	change.Fflags = syscall.NOTE_EXIT
+	if runtime.GOOS == "darwin" {
+		change.Fflags |= noteExitStatus
+	}
+	events := make([]syscall.Kevent_t, 1)
+	for {
+		// If the process has already exited then registering the filter
+		// fails with ESRCH, and we'll return that error.
+		n, err := syscall.Kevent(kq, []syscall.Kevent_t{change}, events, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return -1, err
+		}
+		if n == 1 {
+			break
+		}
+	}
+	status := syscall.WaitStatus(events[0].Data)
+	if !status.Exited() {
+		return -1, nil
+	}
+	return status.ExitStatus(), nil
+}
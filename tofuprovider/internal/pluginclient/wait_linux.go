@@ -0,0 +1,57 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package pluginclient
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// canWaitExited is true on platforms where [waitExited] is supported.
+const canWaitExited = true
+
+const (
+	pPID      = 1 // P_PID in <sys/wait.h>
+	cldExited = 1 // CLD_EXITED in <signal.h>
+)
+
+// siginfo is the prefix of the Linux siginfo_t structure that's relevant
+// for the result of waitid. (MIPS uses a different field order, and so
+// is excluded by this file's build constraints.)
+type siginfo struct {
+	signo  int32
+	errno  int32
+	code   int32
+	_      [unsafe.Sizeof(uintptr(0)) - 4]byte // the following union is pointer-aligned
+	_      int32                               // si_pid
+	_      uint32                              // si_uid
+	status int32
+
+	// siginfo_t is 128 bytes long in total, and so this is more than
+	// enough to leave room for the kernel to write the remainder.
+	_ [128]byte
+}
+
+// waitExited blocks until the child process with the given pid has exited,
+// and then returns its exit code or -1 if it was terminated by a signal.
+//
+// This uses WNOWAIT so that the process remains waitable, because the
+// process is owned by the rpcplugin library that launched it and so it's
+// not our responsibility to reap it.
+func waitExited(pid int) (int, error) {
+	var info siginfo
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info)), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return -1, errno
+		}
+		break
+	}
+	if info.code != cldExited {
+		return -1, nil
+	}
+	return int(info.status), nil
+}
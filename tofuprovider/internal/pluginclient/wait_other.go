@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows) || (linux && (mips || mipsle || mips64 || mips64le))
+
+package pluginclient
+
+import (
+	"errors"
+)
+
+// canWaitExited is true on platforms where [waitExited] is supported.
+const canWaitExited = false
+
+// waitExited would wait for the child process with the given pid to exit,
+// but we don't currently have a way to do that on this platform without
+// interfering with the rpcplugin library's own management of the process.
+func waitExited(pid int) (int, error) {
+	return -1, errors.ErrUnsupported
+}
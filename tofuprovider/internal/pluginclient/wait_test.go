@@ -0,0 +1,104 @@
+package pluginclient
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWaitExited(t *testing.T) {
+	skipUnlessWaitExited(t)
+
+	t.Run("exit code", func(t *testing.T) {
+		cmd := helperCommand(t, "crash")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		// waitExited leaves the process waitable, so we must reap it.
+		defer cmd.Wait()
+
+		exitCode, err := waitExited(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exitCode != helperExitCode {
+			t.Errorf("wrong exit code %d; want %d", exitCode, helperExitCode)
+		}
+	})
+	t.Run("signal", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("processes cannot be terminated by signals on Windows")
+		}
+		cmd := helperCommand(t, "sleep")
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cmd.Wait()
+		if err := cmd.Process.Signal(os.Kill); err != nil {
+			t.Fatal(err)
+		}
+
+		exitCode, err := waitExited(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exitCode != -1 {
+			t.Errorf("wrong exit code %d; want -1", exitCode)
+		}
+	})
+	t.Run("already reaped", func(t *testing.T) {
+		_, err := waitExited(reapedPid(t))
+		if err == nil {
+			t.Error("unexpected success")
+		}
+	})
+}
+
+func TestNewChildProcessCrash(t *testing.T) {
+	skipUnlessWaitExited(t)
+
+	cmd := helperCommand(t, "crash")
+	stderr := NewStderrTail(nil, nil)
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// NewChildProcess is for processes that something else is responsible
+	// for reaping, which is this test.
+	plugin := NewChildProcess(nil, cmd.Process, stderr)
+	defer plugin.Close()
+
+	select {
+	case <-plugin.Exited():
+	case <-time.After(10 * time.Second):
+		t.Fatal("plugin did not exit")
+	}
+	// Waiting for the command also waits for all of the stderr output to
+	// be copied, so that assertCrashed can check it.
+	_ = cmd.Wait()
+
+	assertCrashed(t, plugin)
+}
+
+// skipUnlessWaitExited skips the calling test on platforms where
+// [waitExited] is not supported, and so [NewChildProcess] cannot detect
+// a plugin crashing.
+func skipUnlessWaitExited(t *testing.T) {
+	t.Helper()
+	if !canWaitExited {
+		t.Skipf("exit detection for processes started by rpcplugin is not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+}
+
+// reapedPid returns the process ID of a child process that has already
+// exited and been reaped.
+func reapedPid(t *testing.T) int {
+	t.Helper()
+	cmd := helperCommand(t, "crash")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = cmd.Wait()
+	return cmd.Process.Pid
+}
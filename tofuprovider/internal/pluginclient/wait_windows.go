@@ -0,0 +1,26 @@
+package pluginclient
+
+import (
+	"os"
+)
+
+// canWaitExited is true on platforms where [waitExited] is supported.
+const canWaitExited = true
+
+// waitExited blocks until the child process with the given pid has exited,
+// and then returns its exit code.
+//
+// Windows processes don't need to be reaped, so waiting for the process
+// using our own handle doesn't interfere with the rpcplugin library's
+// management of it.
+func waitExited(pid int) (int, error) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return -1, err
+	}
+	state, err := process.Wait()
+	if err != nil {
+		return -1, err
+	}
+	return state.ExitCode(), nil
+}
@@ -0,0 +1,32 @@
+package tf5
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+)
+
+// attributePath converts the protocol's representation of an attribute path
+// into the equivalent [cty.Path].
+//
+// The second result is false if the path includes a step of a kind that this
+// library doesn't understand, in which case the path cannot be represented
+// and the first result is meaningless.
+func attributePath(proto *tfplugin5.AttributePath) (cty.Path, bool) {
+	ret := make(cty.Path, 0, len(proto.GetSteps()))
+	for _, protoStep := range proto.GetSteps() {
+		// We append steps directly, rather than using cty.Path.GetAttr and
+		// cty.Path.Index, because those copy the entire path each time.
+		switch sel := protoStep.Selector.(type) {
+		case *tfplugin5.AttributePath_Step_AttributeName:
+			ret = append(ret, cty.GetAttrStep{Name: sel.AttributeName})
+		case *tfplugin5.AttributePath_Step_ElementKeyString:
+			ret = append(ret, cty.IndexStep{Key: cty.StringVal(sel.ElementKeyString)})
+		case *tfplugin5.AttributePath_Step_ElementKeyInt:
+			ret = append(ret, cty.IndexStep{Key: cty.NumberIntVal(sel.ElementKeyInt)})
+		default:
+			return nil, false
+		}
+	}
+	return ret, true
+}
@@ -3,25 +3,135 @@ package tf5
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
 	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
 )
 
 // CloseEphemeralResource implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) CloseEphemeralResource(ctx context.Context, req *providerops.CloseEphemeralResourceRequest) (providerops.CloseEphemeralResourceResponse, error) {
-	panic("unimplemented")
+	protoReq := &tfplugin5.CloseEphemeralResource_Request{
+		TypeName: req.ResourceType,
+		Private:  req.ProviderInternal,
+	}
+
+	protoResp, err := p.client.CloseEphemeralResource(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return closeEphemeralResourceResponse{proto: protoResp}, nil
+}
+
+type closeEphemeralResourceResponse struct {
+	proto *tfplugin5.CloseEphemeralResource_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.CloseEphemeralResourceResponse.
+func (c closeEphemeralResourceResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: c.proto.Diagnostics}
 }
 
 // OpenEphemeralResource implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) OpenEphemeralResource(ctx context.Context, req *providerops.OpenEphemeralResourceRequest) (providerops.OpenEphemeralResourceResponse, error) {
-	panic("unimplemented")
+	configVal, err := makeDynamicValueMsgpack(req.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Config value: %w", err)
+	}
+	protoReq := &tfplugin5.OpenEphemeralResource_Request{
+		TypeName:           req.ResourceType,
+		Config:             configVal,
+		ClientCapabilities: prepareClientCapabilities(req.ClientCapabilities),
+	}
+
+	protoResp, err := p.client.OpenEphemeralResource(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return openEphemeralResourceResponse{proto: protoResp}, nil
+}
+
+type openEphemeralResourceResponse struct {
+	proto *tfplugin5.OpenEphemeralResource_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.OpenEphemeralResourceResponse.
+func (o openEphemeralResourceResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: o.proto.Diagnostics}
+}
+
+// Result implements providerops.OpenEphemeralResourceResponse.
+func (o openEphemeralResourceResponse) Result() providerschema.DynamicValueOut {
+	if o.proto.Result == nil {
+		return nil
+	}
+	return dynamicValue{proto: o.proto.Result}
+}
+
+// RenewTime implements providerops.OpenEphemeralResourceResponse.
+func (o openEphemeralResourceResponse) RenewTime() (time.Time, bool) {
+	return renewTime(o.proto.RenewAt)
+}
+
+// ProviderInternal implements providerops.OpenEphemeralResourceResponse.
+func (o openEphemeralResourceResponse) ProviderInternal() []byte {
+	return o.proto.Private
+}
+
+// Deferred implements providerops.OpenEphemeralResourceResponse.
+func (o openEphemeralResourceResponse) Deferred() providerops.Deferred {
+	if o.proto.Deferred == nil {
+		return nil
+	}
+	return deferred{proto: o.proto.Deferred}
 }
 
 // RenewEphemeralResource implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) RenewEphemeralResource(ctx context.Context, req *providerops.RenewEphemeralResourceRequest) (providerops.RenewEphemeralResourceResponse, error) {
-	panic("unimplemented")
+	protoReq := &tfplugin5.RenewEphemeralResource_Request{
+		TypeName: req.ResourceType,
+		Private:  req.ProviderInternal,
+	}
+
+	protoResp, err := p.client.RenewEphemeralResource(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return renewEphemeralResourceResponse{proto: protoResp}, nil
+}
+
+type renewEphemeralResourceResponse struct {
+	proto *tfplugin5.RenewEphemeralResource_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.RenewEphemeralResourceResponse.
+func (r renewEphemeralResourceResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: r.proto.Diagnostics}
+}
+
+// RenewTime implements providerops.RenewEphemeralResourceResponse.
+func (r renewEphemeralResourceResponse) RenewTime() (time.Time, bool) {
+	return renewTime(r.proto.RenewAt)
+}
+
+// ProviderInternal implements providerops.RenewEphemeralResourceResponse.
+func (r renewEphemeralResourceResponse) ProviderInternal() []byte {
+	return r.proto.Private
+}
+
+func renewTime(proto *timestamppb.Timestamp) (time.Time, bool) {
+	if proto == nil {
+		// The provider does not need the object to be renewed.
+		return time.Time{}, false
+	}
+	return proto.AsTime(), true
 }
 
 // ValidateEphemeralResourceConfig implements tofuprovider.GRPCPluginProvider.
@@ -3,26 +3,38 @@ package tf5
 import (
 	"context"
 	"errors"
+	"sync/atomic"
+	"time"
 
-	"go.rpcplugin.org/rpcplugin"
 	"google.golang.org/grpc"
 
 	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
 )
 
 type Provider struct {
 	client tfplugin5.ProviderClient
-	plugin *rpcplugin.Plugin
+
+	// plugin is closed when the provider is closed, to terminate the
+	// connection to the provider and any associated child process.
+	plugin *pluginclient.Plugin
+
+	// serverCaps retains the server capabilities most recently reported by
+	// the provider, so that we can avoid making requests the provider has
+	// declared it cannot support. This is nil until the provider has
+	// reported its capabilities at least once.
+	serverCaps atomic.Pointer[tfplugin5.ServerCapabilities]
 
 	common.SealedImpl
 }
 
-func NewProvider(ctx context.Context, plugin *rpcplugin.Plugin, clientProxy any) (*Provider, error) {
-	return &Provider{
-		client: clientProxy.(tfplugin5.ProviderClient),
+func NewProvider(ctx context.Context, plugin *pluginclient.Plugin, conn grpc.ClientConnInterface) (*Provider, error) {
+	ret := &Provider{
 		plugin: plugin,
-	}, nil
+	}
+	ret.client = tfplugin5.NewProviderClient(plugin.Conn(conn, ret.GracefulStop))
+	return ret, nil
 }
 
 func (p *Provider) ProtocolMajorVersion() int {
@@ -34,13 +46,33 @@ func (p *Provider) ClientProxy() any {
 }
 
 func (p *Provider) Close() error {
-	if p.plugin == nil {
-		return nil // it's okay to call Close multiple times on the same provider instance
+	// It's okay to call Close multiple times on the same provider instance,
+	// because the plugin object ignores subsequent calls.
+	return p.plugin.Close()
+}
+
+func (p *Provider) Shutdown(ctx context.Context, gracePeriod time.Duration) (pluginclient.ShutdownStage, error) {
+	return p.plugin.Shutdown(ctx, gracePeriod, p.GracefulStop)
+}
+
+func (p *Provider) Exited() <-chan struct{} {
+	return p.plugin.Exited()
+}
+
+func (p *Provider) ExitStatus() (int, bool) {
+	return p.plugin.ExitStatus()
+}
+
+// storeServerCapabilities retains the capabilities from a response that
+// can report them, for use by later requests.
+//
+// A response that doesn't include any capabilities at all still tells us
+// that the provider doesn't support any of the optional features.
+func (p *Provider) storeServerCapabilities(caps *tfplugin5.ServerCapabilities) {
+	if caps == nil {
+		caps = &tfplugin5.ServerCapabilities{}
 	}
-	plugin := p.plugin
-	p.plugin = nil
-	p.client = nil // subsequent usage of the client will panic
-	return plugin.Close()
+	p.serverCaps.Store(caps)
 }
 
 func (p *Provider) GracefulStop(ctx context.Context) error {
@@ -53,11 +85,3 @@ func (p *Provider) GracefulStop(ctx context.Context) error {
 	}
 	return nil
 }
-
-// PluginClient is an adapter used by the main package to obtain the low-level
-// gRPC client proxy when protocol version 5 is selected.
-type PluginClient struct{}
-
-func (c PluginClient) ClientProxy(ctx context.Context, conn *grpc.ClientConn) (any, error) {
-	return tfplugin5.NewProviderClient(conn), nil
-}
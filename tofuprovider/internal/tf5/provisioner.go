@@ -0,0 +1,186 @@
+package tf5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"google.golang.org/grpc"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+	"github.com/opentofu/provider-client/tofuprovider/provisionerops"
+)
+
+// Provisioner is the implementation of tofuprovider.GRPCPluginProvisioner.
+//
+// Provisioner plugins are only supported in protocol version 5, so there is
+// no equivalent of this in package tf6.
+type Provisioner struct {
+	client tfplugin5.ProvisionerClient
+
+	// plugin is closed when the provisioner is closed, to terminate the
+	// plugin's child process.
+	plugin *pluginclient.Plugin
+
+	common.SealedImpl
+}
+
+func NewProvisioner(ctx context.Context, plugin *pluginclient.Plugin, conn grpc.ClientConnInterface) (*Provisioner, error) {
+	ret := &Provisioner{
+		plugin: plugin,
+	}
+	ret.client = tfplugin5.NewProvisionerClient(plugin.Conn(conn, ret.GracefulStop))
+	return ret, nil
+}
+
+func (p *Provisioner) ProtocolMajorVersion() int {
+	return 5
+}
+
+func (p *Provisioner) ClientProxy() any {
+	return p.client
+}
+
+func (p *Provisioner) Close() error {
+	// It's okay to call Close multiple times on the same provisioner
+	// instance, because the plugin object ignores subsequent calls.
+	return p.plugin.Close()
+}
+
+// GetSchema implements tofuprovider.Provisioner.
+func (p *Provisioner) GetSchema(ctx context.Context, req *provisionerops.GetSchemaRequest) (provisionerops.GetSchemaResponse, error) {
+	protoReq := &tfplugin5.GetProvisionerSchema_Request{
+		// There are currently no fields in provisionerops.GetSchemaRequest,
+		// so nothing to populate here.
+	}
+	protoResp, err := p.client.GetSchema(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return getProvisionerSchemaResponse{proto: protoResp}, nil
+}
+
+type getProvisionerSchemaResponse struct {
+	proto *tfplugin5.GetProvisionerSchema_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements provisionerops.GetSchemaResponse.
+func (g getProvisionerSchemaResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: g.proto.Diagnostics}
+}
+
+// ProvisionerConfigSchema implements provisionerops.GetSchemaResponse.
+func (g getProvisionerSchemaResponse) ProvisionerConfigSchema() providerschema.Schema {
+	if g.proto.Provisioner == nil {
+		return nil
+	}
+	return schema{proto: g.proto.Provisioner}
+}
+
+// ValidateConfig implements tofuprovider.Provisioner.
+func (p *Provisioner) ValidateConfig(ctx context.Context, req *provisionerops.ValidateConfigRequest) (provisionerops.ValidateConfigResponse, error) {
+	configVal, err := makeDynamicValueMsgpack(req.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Config value: %w", err)
+	}
+	protoReq := &tfplugin5.ValidateProvisionerConfig_Request{
+		Config: configVal,
+	}
+
+	protoResp, err := p.client.ValidateProvisionerConfig(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return validateProvisionerConfigResponse{proto: protoResp}, nil
+}
+
+type validateProvisionerConfigResponse struct {
+	proto *tfplugin5.ValidateProvisionerConfig_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements provisionerops.ValidateConfigResponse.
+func (v validateProvisionerConfigResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: v.proto.Diagnostics}
+}
+
+// ProvisionResource implements tofuprovider.Provisioner.
+func (p *Provisioner) ProvisionResource(ctx context.Context, req *provisionerops.ProvisionResourceRequest) iter.Seq2[provisionerops.ProvisionResourceEvent, error] {
+	return func(yield func(provisionerops.ProvisionResourceEvent, error) bool) {
+		configVal, err := makeDynamicValueMsgpack(req.Config)
+		if err != nil {
+			yield(nil, fmt.Errorf("invalid Config value: %w", err))
+			return
+		}
+		var connectionVal *tfplugin5.DynamicValue
+		if req.Connection != providerschema.NoDynamicValue {
+			connectionVal, err = makeDynamicValueMsgpack(req.Connection)
+			if err != nil {
+				yield(nil, fmt.Errorf("invalid Connection value: %w", err))
+				return
+			}
+		}
+		protoReq := &tfplugin5.ProvisionResource_Request{
+			Config:     configVal,
+			Connection: connectionVal,
+		}
+
+		// If the caller stops iterating early then we'll cancel the
+		// stream so that the provisioner can stop sending events.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := p.client.ProvisionResource(ctx, protoReq)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			protoResp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return // the provisioner has finished
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(provisionResourceEvent{proto: protoResp}, nil) {
+				return
+			}
+		}
+	}
+}
+
+type provisionResourceEvent struct {
+	proto *tfplugin5.ProvisionResource_Response
+	common.SealedImpl
+}
+
+// Output implements provisionerops.ProvisionResourceEvent.
+func (p provisionResourceEvent) Output() string {
+	return p.proto.Output
+}
+
+// Diagnostics implements provisionerops.ProvisionResourceEvent.
+func (p provisionResourceEvent) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: p.proto.Diagnostics}
+}
+
+// GracefulStop implements tofuprovider.Provisioner.
+func (p *Provisioner) GracefulStop(ctx context.Context) error {
+	resp, err := p.client.Stop(ctx, &tfplugin5.Stop_Request{})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package tf5
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin5"
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+// GetResourceIdentitySchemas implements tofuprovider.GRPCPluginProvider.
+func (p *Provider) GetResourceIdentitySchemas(ctx context.Context, req *providerops.GetResourceIdentitySchemasRequest) (providerops.GetResourceIdentitySchemasResponse, error) {
+	protoReq := &tfplugin5.GetResourceIdentitySchemas_Request{
+		// There are currently no fields in
+		// providerops.GetResourceIdentitySchemasRequest, so nothing to
+		// populate here.
+	}
+	protoResp, err := p.client.GetResourceIdentitySchemas(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return getResourceIdentitySchemasResponse{proto: protoResp}, nil
+}
+
+type getResourceIdentitySchemasResponse struct {
+	proto *tfplugin5.GetResourceIdentitySchemas_Response
+
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.GetResourceIdentitySchemasResponse.
+func (g getResourceIdentitySchemasResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: g.proto.Diagnostics}
+}
+
+// IdentitySchemas implements providerops.GetResourceIdentitySchemasResponse.
+func (g getResourceIdentitySchemasResponse) IdentitySchemas() iter.Seq2[string, providerschema.IdentitySchema] {
+	return common.MapSeq2(maps.All(g.proto.IdentitySchemas), func(name string, protoSchema *tfplugin5.ResourceIdentitySchema) (string, providerschema.IdentitySchema) {
+		return name, identitySchema{proto: protoSchema}
+	})
+}
+
+type identitySchema struct {
+	proto *tfplugin5.ResourceIdentitySchema
+	common.SealedImpl
+}
+
+// IdentityVersion implements providerschema.IdentitySchema.
+func (i identitySchema) IdentityVersion() int64 {
+	return i.proto.Version
+}
+
+// Attributes implements providerschema.IdentitySchema.
+func (i identitySchema) Attributes() iter.Seq2[string, providerschema.IdentityAttribute] {
+	return common.MapSeqToSeq2(slices.Values(i.proto.IdentityAttributes), func(protoAttr *tfplugin5.ResourceIdentitySchema_IdentityAttribute) (string, providerschema.IdentityAttribute) {
+		return protoAttr.Name, identityAttribute{proto: protoAttr}
+	})
+}
+
+type identityAttribute struct {
+	proto *tfplugin5.ResourceIdentitySchema_IdentityAttribute
+	common.SealedImpl
+}
+
+// Type implements providerschema.IdentityAttribute.
+func (i identityAttribute) Type() providerschema.TypeConstraint {
+	if len(i.proto.Type) == 0 {
+		return nil
+	}
+	return common.CtyTypeJSON(i.proto.Type)
+}
+
+// ImportUsage implements providerschema.IdentityAttribute.
+func (i identityAttribute) ImportUsage() providerschema.IdentityImportUsage {
+	switch {
+	case i.proto.RequiredForImport && !i.proto.OptionalForImport:
+		return providerschema.IdentityRequiredForImport
+	case !i.proto.RequiredForImport && i.proto.OptionalForImport:
+		return providerschema.IdentityOptionalForImport
+	default:
+		return providerschema.IdentityImportUsageUnsupported
+	}
+}
+
+// DocDescription implements providerschema.IdentityAttribute.
+func (i identityAttribute) DocDescription() (string, providerschema.DocStringFormat) {
+	// The protocol specifies that identity attribute descriptions are
+	// always written in Markdown.
+	return i.proto.Description, providerschema.DocStringMarkdown
+}
+
+// UpgradeManagedResourceIdentity implements tofuprovider.GRPCPluginProvider.
+func (p *Provider) UpgradeManagedResourceIdentity(ctx context.Context, req *providerops.UpgradeManagedResourceIdentityRequest) (providerops.UpgradeManagedResourceIdentityResponse, error) {
+	if req.ResourceType == "" {
+		return nil, fmt.Errorf("missing required ResourceType")
+	}
+	rawIdentity, err := makeRawState(req.PrevIdentityRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PrevIdentityRaw value: %w", err)
+	}
+
+	protoReq := &tfplugin5.UpgradeResourceIdentity_Request{
+		TypeName:    req.ResourceType,
+		Version:     req.IdentityVersion,
+		RawIdentity: rawIdentity,
+	}
+
+	protoResp, err := p.client.UpgradeResourceIdentity(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return upgradeManagedResourceIdentityResponse{proto: protoResp}, nil
+}
+
+type upgradeManagedResourceIdentityResponse struct {
+	proto *tfplugin5.UpgradeResourceIdentity_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.UpgradeManagedResourceIdentityResponse.
+func (u upgradeManagedResourceIdentityResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: u.proto.Diagnostics}
+}
+
+// UpgradedIdentity implements providerops.UpgradeManagedResourceIdentityResponse.
+func (u upgradeManagedResourceIdentityResponse) UpgradedIdentity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(u.proto.UpgradedIdentity)
+}
+
+func makeResourceIdentityData(dv providerschema.DynamicValueIn) (*tfplugin5.ResourceIdentityData, error) {
+	if dv == providerschema.NoDynamicValue {
+		// Resource identity is always optional, because not all providers
+		// support it and even those that do won't have returned any
+		// identity data for an object that doesn't exist yet.
+		return nil, nil
+	}
+	identityVal, err := makeDynamicValueMsgpack(dv)
+	if err != nil {
+		return nil, err
+	}
+	return &tfplugin5.ResourceIdentityData{
+		IdentityData: identityVal,
+	}, nil
+}
+
+func resourceIdentityValue(proto *tfplugin5.ResourceIdentityData) providerschema.DynamicValueOut {
+	if proto == nil || proto.IdentityData == nil {
+		return nil
+	}
+	return dynamicValue{proto: proto.IdentityData}
+}
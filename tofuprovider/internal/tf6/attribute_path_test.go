@@ -0,0 +1,97 @@
+package tf6
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+)
+
+func TestAttributePath(t *testing.T) {
+	attrStep := func(name string) *tfplugin6.AttributePath_Step {
+		return &tfplugin6.AttributePath_Step{
+			Selector: &tfplugin6.AttributePath_Step_AttributeName{AttributeName: name},
+		}
+	}
+	unknownStep := &tfplugin6.AttributePath_Step{} // no selector at all
+
+	tests := map[string]struct {
+		proto  *tfplugin6.AttributePath
+		want   cty.Path
+		wantOK bool
+	}{
+		"nil": {
+			proto:  nil,
+			want:   cty.Path{},
+			wantOK: true,
+		},
+		"all step kinds": {
+			proto: &tfplugin6.AttributePath{
+				Steps: []*tfplugin6.AttributePath_Step{
+					attrStep("foo"),
+					{Selector: &tfplugin6.AttributePath_Step_ElementKeyString{ElementKeyString: "bar"}},
+					{Selector: &tfplugin6.AttributePath_Step_ElementKeyInt{ElementKeyInt: 2}},
+					attrStep("baz"),
+				},
+			},
+			want:   cty.GetAttrPath("foo").Index(cty.StringVal("bar")).Index(cty.NumberIntVal(2)).GetAttr("baz"),
+			wantOK: true,
+		},
+		"unknown first step": {
+			proto: &tfplugin6.AttributePath{
+				Steps: []*tfplugin6.AttributePath_Step{unknownStep, attrStep("foo")},
+			},
+			wantOK: false,
+		},
+		"unknown later step": {
+			proto: &tfplugin6.AttributePath{
+				Steps: []*tfplugin6.AttributePath_Step{attrStep("foo"), unknownStep},
+			},
+			wantOK: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := attributePath(test.proto)
+			if ok != test.wantOK {
+				t.Fatalf("wrong ok %t; want %t", ok, test.wantOK)
+			}
+			if ok && !got.Equals(test.want) {
+				t.Errorf("wrong path\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAttributePathUnknownStep(t *testing.T) {
+	unknownPath := &tfplugin6.AttributePath{
+		Steps: []*tfplugin6.AttributePath_Step{{}},
+	}
+	knownPath := &tfplugin6.AttributePath{
+		Steps: []*tfplugin6.AttributePath_Step{
+			{Selector: &tfplugin6.AttributePath_Step_AttributeName{AttributeName: "foo"}},
+		},
+	}
+
+	t.Run("RequiresReplace", func(t *testing.T) {
+		resp := planManagedResourceChangeResponse{
+			proto: &tfplugin6.PlanResourceChange_Response{
+				RequiresReplace: []*tfplugin6.AttributePath{unknownPath, knownPath},
+			},
+		}
+		got := slices.Collect(resp.RequiresReplace())
+		if len(got) != 1 || !got[0].Equals(cty.GetAttrPath("foo")) {
+			t.Errorf("wrong paths %#v; want only the path to foo", got)
+		}
+	})
+	t.Run("diagnostic", func(t *testing.T) {
+		diag := diagnostic{
+			proto: &tfplugin6.Diagnostic{Attribute: unknownPath},
+		}
+		if path, ok := diag.AttributePath(); ok {
+			t.Errorf("unexpected path %#v", path)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"iter"
 	"slices"
 
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
 	"github.com/opentofu/provider-client/tofuprovider/providerops"
@@ -59,3 +61,9 @@ func (d diagnostic) Severity() providerops.DiagnosticSeverity {
 func (d diagnostic) Summary() string {
 	return d.proto.Summary
 }
+
+// AttributePath implements providerops.Diagnostic.
+func (d diagnostic) AttributePath() (cty.Path, bool) {
+	path, ok := attributePath(d.proto.Attribute)
+	return path, ok && len(path) != 0
+}
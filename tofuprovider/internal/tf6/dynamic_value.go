@@ -22,6 +22,23 @@ func makeDynamicValueMsgpack(dv providerschema.DynamicValueIn) (*tfplugin6.Dynam
 	}, nil
 }
 
+func makeRawState(raw providerschema.RawState) (*tfplugin6.RawState, error) {
+	switch {
+	case len(raw.JSON) != 0 && len(raw.Flatmap) != 0:
+		return nil, fmt.Errorf("must not set both JSON and Flatmap")
+	case len(raw.JSON) != 0:
+		return &tfplugin6.RawState{
+			Json: raw.JSON,
+		}, nil
+	case len(raw.Flatmap) != 0:
+		return &tfplugin6.RawState{
+			Flatmap: raw.Flatmap,
+		}, nil
+	default:
+		return nil, fmt.Errorf("missing required value")
+	}
+}
+
 type dynamicValue struct {
 	proto *tfplugin6.DynamicValue
 	common.SealedImpl
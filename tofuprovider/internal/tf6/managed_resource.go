@@ -6,6 +6,8 @@ import (
 	"iter"
 	"slices"
 
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
 	"github.com/opentofu/provider-client/tofuprovider/providerops"
@@ -27,6 +29,11 @@ func (p *Provider) ApplyManagedResourceChange(ctx context.Context, req *provider
 		return nil, fmt.Errorf("invalid Config value: %w", err)
 	}
 
+	plannedNewIdentity, err := makeResourceIdentityData(req.PlannedNewIdentity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PlannedNewIdentity value: %w", err)
+	}
+
 	var providerMeta *tfplugin6.DynamicValue
 	if req.ProviderMeta != providerschema.NoDynamicValue {
 		providerMeta, err = makeDynamicValueMsgpack(req.ProviderMeta)
@@ -36,12 +43,13 @@ func (p *Provider) ApplyManagedResourceChange(ctx context.Context, req *provider
 	}
 
 	protoReq := &tfplugin6.ApplyResourceChange_Request{
-		TypeName:       req.ResourceType,
-		PriorState:     priorState,
-		PlannedState:   plannedNewState,
-		Config:         config,
-		PlannedPrivate: req.PlannedProviderInternal,
-		ProviderMeta:   providerMeta,
+		TypeName:        req.ResourceType,
+		PriorState:      priorState,
+		PlannedState:    plannedNewState,
+		Config:          config,
+		PlannedPrivate:  req.PlannedProviderInternal,
+		ProviderMeta:    providerMeta,
+		PlannedIdentity: plannedNewIdentity,
 	}
 
 	protoResp, err := p.client.ApplyResourceChange(ctx, protoReq)
@@ -53,10 +61,16 @@ func (p *Provider) ApplyManagedResourceChange(ctx context.Context, req *provider
 
 // ImportManagedResourceState implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) ImportManagedResourceState(ctx context.Context, req *providerops.ImportManagedResourceStateRequest) (providerops.ImportManagedResourceStateResponse, error) {
+	identity, err := makeResourceIdentityData(req.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Identity value: %w", err)
+	}
+
 	protoReq := &tfplugin6.ImportResourceState_Request{
 		TypeName:           req.ResourceType,
 		Id:                 req.ID,
 		ClientCapabilities: prepareClientCapabilities(req.ClientCapabilities),
+		Identity:           identity,
 	}
 
 	protoResp, err := p.client.ImportResourceState(ctx, protoReq)
@@ -68,7 +82,41 @@ func (p *Provider) ImportManagedResourceState(ctx context.Context, req *provider
 
 // MoveManagedResourceState implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) MoveManagedResourceState(ctx context.Context, req *providerops.MoveManagedResourceStateRequest) (providerops.MoveManagedResourceStateResponse, error) {
-	panic("unimplemented")
+	// We refuse only if the provider has told this object that it doesn't
+	// support moving state. If it hasn't reported its capabilities yet, such
+	// as when the caller obtained the schema some other way, we send the
+	// request anyway and let the provider decide.
+	if caps := p.serverCaps.Load(); caps != nil && !caps.MoveResourceState {
+		return nil, providerops.UnsupportedOperationError{Operation: "MoveManagedResourceState"}
+	}
+	sourceState, err := makeRawState(req.SourceStateRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SourceStateRaw value: %w", err)
+	}
+	var sourceIdentity *tfplugin6.RawState
+	if len(req.SourceIdentityRaw.JSON) != 0 || len(req.SourceIdentityRaw.Flatmap) != 0 {
+		sourceIdentity, err = makeRawState(req.SourceIdentityRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SourceIdentityRaw value: %w", err)
+		}
+	}
+
+	protoReq := &tfplugin6.MoveResourceState_Request{
+		SourceProviderAddress:       req.SourceProviderAddress,
+		SourceTypeName:              req.SourceResourceType,
+		SourceSchemaVersion:         req.SourceSchemaVersion,
+		SourceState:                 sourceState,
+		SourcePrivate:               req.SourceProviderInternal,
+		SourceIdentity:              sourceIdentity,
+		SourceIdentitySchemaVersion: req.SourceIdentitySchemaVersion,
+		TargetTypeName:              req.TargetResourceType,
+	}
+
+	protoResp, err := p.client.MoveResourceState(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return moveManagedResourceStateResponse{proto: protoResp}, nil
 }
 
 // PlanManagedResourceChange implements tofuprovider.GRPCPluginProvider.
@@ -85,6 +133,10 @@ func (p *Provider) PlanManagedResourceChange(ctx context.Context, req *providero
 	if err != nil {
 		return nil, fmt.Errorf("invalid Config value: %w", err)
 	}
+	priorIdentity, err := makeResourceIdentityData(req.PriorIdentity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PriorIdentity value: %w", err)
+	}
 
 	var providerMeta *tfplugin6.DynamicValue
 	if req.ProviderMeta != providerschema.NoDynamicValue {
@@ -102,6 +154,7 @@ func (p *Provider) PlanManagedResourceChange(ctx context.Context, req *providero
 		PriorPrivate:       req.PriorProviderInternal,
 		ProviderMeta:       providerMeta,
 		ClientCapabilities: prepareClientCapabilities(req.ClientCapabilities),
+		PriorIdentity:      priorIdentity,
 	}
 
 	protoResp, err := p.client.PlanResourceChange(ctx, protoReq)
@@ -117,6 +170,10 @@ func (p *Provider) ReadManagedResource(ctx context.Context, req *providerops.Rea
 	if err != nil {
 		return nil, fmt.Errorf("invalid CurrentState value: %w", err)
 	}
+	currentIdentity, err := makeResourceIdentityData(req.CurrentIdentity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CurrentIdentity value: %w", err)
+	}
 
 	var providerMeta *tfplugin6.DynamicValue
 	if req.ProviderMeta != providerschema.NoDynamicValue {
@@ -132,6 +189,7 @@ func (p *Provider) ReadManagedResource(ctx context.Context, req *providerops.Rea
 		Private:            req.ProviderInternal,
 		ProviderMeta:       providerMeta,
 		ClientCapabilities: prepareClientCapabilities(req.ClientCapabilities),
+		CurrentIdentity:    currentIdentity,
 	}
 
 	protoResp, err := p.client.ReadResource(ctx, protoReq)
@@ -143,7 +201,25 @@ func (p *Provider) ReadManagedResource(ctx context.Context, req *providerops.Rea
 
 // UpgradeManagedResourceState implements tofuprovider.GRPCPluginProvider.
 func (p *Provider) UpgradeManagedResourceState(ctx context.Context, req *providerops.UpgradeManagedResourceStateRequest) (providerops.UpgradeManagedResourceStateResponse, error) {
-	panic("unimplemented")
+	if req.ResourceType == "" {
+		return nil, fmt.Errorf("missing required ResourceType")
+	}
+	rawState, err := makeRawState(req.PrevStateRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PrevStateRaw value: %w", err)
+	}
+
+	protoReq := &tfplugin6.UpgradeResourceState_Request{
+		TypeName: req.ResourceType,
+		Version:  req.SchemaVersion,
+		RawState: rawState,
+	}
+
+	protoResp, err := p.client.UpgradeResourceState(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return upgradeManagedResourceStateResponse{proto: protoResp}, nil
 }
 
 // ValidateManagedResourceConfig implements tofuprovider.GRPCPluginProvider.
@@ -207,6 +283,29 @@ func (p planManagedResourceChangeResponse) Deferred() providerops.Deferred {
 	return deferred{proto: p.proto.Deferred}
 }
 
+// RequiresReplace implements providerops.PlanManagedResourceChangeResponse.
+func (p planManagedResourceChangeResponse) RequiresReplace() iter.Seq[cty.Path] {
+	return func(yield func(cty.Path) bool) {
+		for _, proto := range p.proto.RequiresReplace {
+			path, ok := attributePath(proto)
+			if !ok {
+				// We can't represent this path, and using only the steps
+				// we do understand would refer to a containing object,
+				// which could make every change to it require replacement.
+				continue
+			}
+			if !yield(path) {
+				return
+			}
+		}
+	}
+}
+
+// PlannedNewIdentity implements providerops.PlanManagedResourceChangeResponse.
+func (p planManagedResourceChangeResponse) PlannedNewIdentity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(p.proto.PlannedIdentity)
+}
+
 type applyManagedResourceChangeResponse struct {
 	proto *tfplugin6.ApplyResourceChange_Response
 	common.SealedImpl
@@ -235,6 +334,11 @@ func (a applyManagedResourceChangeResponse) LegacyTypeSystem() bool {
 	return a.proto.LegacyTypeSystem
 }
 
+// NewIdentity implements providerops.ApplyManagedResourceChangeResponse.
+func (a applyManagedResourceChangeResponse) NewIdentity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(a.proto.NewIdentity)
+}
+
 type readManagedResourceResponse struct {
 	proto *tfplugin6.ReadResource_Response
 	common.SealedImpl
@@ -266,6 +370,11 @@ func (r readManagedResourceResponse) Deferred() providerops.Deferred {
 	return deferred{proto: r.proto.Deferred}
 }
 
+// NewIdentity implements providerops.ReadManagedResourceResponse.
+func (r readManagedResourceResponse) NewIdentity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(r.proto.NewIdentity)
+}
+
 type importManagedResourceStateResponse struct {
 	proto *tfplugin6.ImportResourceState_Response
 	common.SealedImpl
@@ -305,3 +414,54 @@ func (i importedManagedResource) State() providerschema.DynamicValueOut {
 func (i importedManagedResource) ProviderInternal() []byte {
 	return i.proto.Private
 }
+
+// Identity implements providerops.ImportedManagedResource.
+func (i importedManagedResource) Identity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(i.proto.Identity)
+}
+
+type upgradeManagedResourceStateResponse struct {
+	proto *tfplugin6.UpgradeResourceState_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.UpgradeManagedResourceStateResponse.
+func (u upgradeManagedResourceStateResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: u.proto.Diagnostics}
+}
+
+// UpgradedState implements providerops.UpgradeManagedResourceStateResponse.
+func (u upgradeManagedResourceStateResponse) UpgradedState() providerschema.DynamicValueOut {
+	if u.proto.UpgradedState == nil {
+		return nil
+	}
+	return dynamicValue{proto: u.proto.UpgradedState}
+}
+
+type moveManagedResourceStateResponse struct {
+	proto *tfplugin6.MoveResourceState_Response
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.MoveManagedResourceStateResponse.
+func (m moveManagedResourceStateResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: m.proto.Diagnostics}
+}
+
+// TargetState implements providerops.MoveManagedResourceStateResponse.
+func (m moveManagedResourceStateResponse) TargetState() providerschema.DynamicValueOut {
+	if m.proto.TargetState == nil {
+		return nil
+	}
+	return dynamicValue{proto: m.proto.TargetState}
+}
+
+// TargetProviderInternal implements providerops.MoveManagedResourceStateResponse.
+func (m moveManagedResourceStateResponse) TargetProviderInternal() []byte {
+	return m.proto.TargetPrivate
+}
+
+// TargetIdentity implements providerops.MoveManagedResourceStateResponse.
+func (m moveManagedResourceStateResponse) TargetIdentity() providerschema.DynamicValueOut {
+	return resourceIdentityValue(m.proto.TargetIdentity)
+}
@@ -12,6 +12,63 @@ import (
 	"github.com/opentofu/provider-client/tofuprovider/providerschema"
 )
 
+func (p *Provider) GetMetadata(ctx context.Context, req *providerops.GetMetadataRequest) (providerops.GetMetadataResponse, error) {
+	protoReq := &tfplugin6.GetMetadata_Request{
+		// There are currently no fields in providerops.GetMetadataRequest,
+		// so nothing to populate here.
+	}
+	protoResp, err := p.client.GetMetadata(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	p.storeServerCapabilities(protoResp.ServerCapabilities)
+	return getMetadataResponse{proto: protoResp}, nil
+}
+
+type getMetadataResponse struct {
+	proto *tfplugin6.GetMetadata_Response
+
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: g.proto.Diagnostics}
+}
+
+// ServerCapabilities implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) ServerCapabilities() providerops.ServerCapabilities {
+	return serverCapabilities{proto: g.proto.ServerCapabilities}
+}
+
+// ManagedResourceTypeNames implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) ManagedResourceTypeNames() iter.Seq[string] {
+	return common.MapSeq(slices.Values(g.proto.Resources), func(proto *tfplugin6.GetMetadata_ResourceMetadata) string {
+		return proto.TypeName
+	})
+}
+
+// DataResourceTypeNames implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) DataResourceTypeNames() iter.Seq[string] {
+	return common.MapSeq(slices.Values(g.proto.DataSources), func(proto *tfplugin6.GetMetadata_DataSourceMetadata) string {
+		return proto.TypeName
+	})
+}
+
+// EphemeralResourceTypeNames implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) EphemeralResourceTypeNames() iter.Seq[string] {
+	return common.MapSeq(slices.Values(g.proto.EphemeralResources), func(proto *tfplugin6.GetMetadata_EphemeralResourceMetadata) string {
+		return proto.TypeName
+	})
+}
+
+// FunctionNames implements providerops.GetMetadataResponse.
+func (g getMetadataResponse) FunctionNames() iter.Seq[string] {
+	return common.MapSeq(slices.Values(g.proto.Functions), func(proto *tfplugin6.GetMetadata_FunctionMetadata) string {
+		return proto.Name
+	})
+}
+
 func (p *Provider) GetProviderSchema(ctx context.Context, req *providerops.GetProviderSchemaRequest) (providerops.GetProviderSchemaResponse, error) {
 	protoReq := &tfplugin6.GetProviderSchema_Request{
 		// There are currently no fields in providerops.GetProviderSchemaRequest,
@@ -21,6 +78,7 @@ func (p *Provider) GetProviderSchema(ctx context.Context, req *providerops.GetPr
 	if err != nil {
 		return nil, err
 	}
+	p.storeServerCapabilities(protoResp.ServerCapabilities)
 	return getProviderSchemaResponse{proto: protoResp}, nil
 }
 
@@ -46,7 +104,34 @@ func (g getProviderSchemaResponse) ServerCapabilities() providerops.ServerCapabi
 }
 
 func (p *Provider) GetFunctions(ctx context.Context, req *providerops.GetFunctionsRequest) (providerops.GetFunctionsResponse, error) {
-	panic("unimplemented")
+	protoReq := &tfplugin6.GetFunctions_Request{
+		// There are currently no fields in providerops.GetFunctionsRequest,
+		// so nothing to populate here.
+	}
+	// If the provider predates the GetFunctions operation then this returns
+	// a gRPC "unimplemented" error, which we return verbatim so that
+	// providerops.IsUnimplementedErr can recognize it.
+	protoResp, err := p.client.GetFunctions(ctx, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	return getFunctionsResponse{proto: protoResp}, nil
+}
+
+type getFunctionsResponse struct {
+	proto *tfplugin6.GetFunctions_Response
+
+	common.SealedImpl
+}
+
+// Diagnostics implements providerops.GetFunctionsResponse.
+func (g getFunctionsResponse) Diagnostics() providerops.Diagnostics {
+	return diagnostics{proto: g.proto.Diagnostics}
+}
+
+// FunctionSignatures implements providerops.GetFunctionsResponse.
+func (g getFunctionsResponse) FunctionSignatures() iter.Seq2[string, providerschema.FunctionSignature] {
+	return namedFunctionsSeq(g.proto.Functions)
 }
 
 type providerSchema struct {
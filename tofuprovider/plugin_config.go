@@ -0,0 +1,185 @@
+package tofuprovider
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+// GRPCPluginConfig describes how to launch a "gRPC-style" plugin, for use
+// with [StartGRPCPluginWithConfig].
+type GRPCPluginConfig struct {
+	// Executable is the path to the plugin executable to run. This is
+	// required.
+	//
+	// This has the same meaning as the first argument to [exec.Command],
+	// including searching the PATH environment variable if the given name
+	// does not contain any path separators.
+	Executable string
+
+	// Args are the command line arguments to pass to the plugin executable,
+	// not including the name of the executable itself.
+	Args []string
+
+	// Env, if non-nil, is the complete environment for the child process
+	// in the same "key=value" format used by [exec.Cmd.Env]. If nil, the
+	// child process inherits the environment of the current process.
+	//
+	// To add to or override the inherited environment, start from the result
+	// of [os.Environ] and append additional entries. If the same key appears
+	// more than once then the last entry takes priority. To run the plugin
+	// with a scrubbed environment, specify only the variables the plugin
+	// actually needs.
+	//
+	// The plugin handshake process adds some additional environment variables
+	// of its own, such as TF_PLUGIN_MAGIC_COOKIE, so callers should not set
+	// those variables themselves.
+	Env []string
+
+	// Dir, if not empty, is the working directory for the child process. If
+	// empty, the child process uses the current working directory of the
+	// calling process.
+	Dir string
+
+	// ExtraFiles are additional open files to be inherited by the child
+	// process, with the same meaning as [exec.Cmd.ExtraFiles].
+	ExtraFiles []*os.File
+
+	// PrepareCmd, if non-nil, is called with the command that's about to be
+	// used to launch the plugin, after all of the other settings in this
+	// object have been applied but before the child process is started.
+	//
+	// The callback may modify the command in any way that doesn't conflict
+	// with the plugin handshake, such as setting [exec.Cmd.SysProcAttr] to
+	// run the plugin in its own process group. The callback must not set
+	// the Stdin, Stdout, or Stderr fields and must not start the command
+	// itself. If the callback returns an error then the plugin is not
+	// launched and that error is returned to the caller.
+	//
+	// If the plugin must be launched more than once to negotiate a protocol
+	// version, as described for ProtocolVersions, then PrepareCmd is called
+	// separately for each launch.
+	PrepareCmd func(cmd *exec.Cmd) error
+
+	// ProtocolVersions, if not empty, is the set of protocol major versions
+	// that the client may use with the plugin, in order of preference.
+	//
+	// If empty, the client offers all of the versions supported by this
+	// library and the plugin chooses which to use.
+	//
+	// By convention plugins choose the newest of the offered versions that
+	// they support, so when the preferred versions are listed newest first
+	// the plugin is launched only once, offering all of the versions at
+	// once. Otherwise the plugin is launched separately with each version in
+	// turn, in the given order, until it selects the offered version. In
+	// either case, launching fails if the plugin does not support any of the
+	// given versions, or immediately if a launch fails for any other reason.
+	//
+	// Each of those separate launches starts a new child process and waits
+	// for its handshake, so a preference order other than newest first can
+	// multiply the time taken to start a plugin that supports only the less
+	// preferred versions. Callers that don't need a particular preference
+	// should list the versions newest first, or leave this field empty.
+	//
+	// A plugin that supports none of the offered versions typically completes
+	// its handshake with its own default version anyway, in which case the
+	// launch error reports the version that the plugin selected.
+	ProtocolVersions []int
+
+	// AutoMTLS, if true, causes the client to authenticate the connection
+	// to the plugin using mutually-authenticated TLS, using the same
+	// mechanism as the "AutoMTLS" option in HashiCorp's go-plugin library.
+	//
+	// In this mode the client generates an ephemeral client certificate
+	// and passes it to the plugin in the PLUGIN_CLIENT_CERT environment
+	// variable, and the plugin must respond with its own server certificate
+	// as part of the handshake. The connection then only trusts that server
+	// certificate. Launching fails if the plugin does not support this.
+	//
+	// Most plugins built with the HashiCorp plugin SDK or plugin framework
+	// support this mechanism.
+	AutoMTLS bool
+
+	// TimeoutPolicy, if non-nil, is the default timeout policy for all
+	// operations on the plugin.
+	//
+	// Individual calls can override this policy by passing a context
+	// created by [providerops.ContextWithTimeoutPolicy].
+	TimeoutPolicy *providerops.TimeoutPolicy
+
+	// Record, if non-nil, receives a recording of every request made to the
+	// plugin and the plugin's response to each, which can be replayed later
+	// using [NewReplayProvider].
+	//
+	// The recording is written incrementally as requests complete, so the
+	// caller must not close the writer until the provider has been closed.
+	// If writing fails then the provider's Close method returns an error.
+	//
+	// A request that fails because its context was canceled or reached its
+	// deadline, including a deadline from TimeoutPolicy, is not recorded,
+	// because its outcome didn't depend on the provider.
+	//
+	// Recordings include all of the data sent to and from the provider,
+	// which might include sensitive values such as credentials.
+	//
+	// Recording is available only for plugins launched using
+	// [StartGRPCPluginWithConfig]. Providers obtained from
+	// [ConnectGRPCPlugin] or [NewGRPCProvider] cannot be recorded.
+	Record io.Writer
+}
+
+// protocolVersionOffers returns the sets of protocol versions to offer to
+// the plugin during negotiation, in the order they should be tried.
+func (c *GRPCPluginConfig) protocolVersionOffers() ([][]int, error) {
+	if len(c.ProtocolVersions) == 0 {
+		return [][]int{slices.Sorted(maps.Keys(grpcProviderProtoVersions))}, nil
+	}
+
+	seen := make(map[int]struct{}, len(c.ProtocolVersions))
+	for _, v := range c.ProtocolVersions {
+		if _, ok := grpcProviderProtoVersions[v]; !ok {
+			return nil, fmt.Errorf("unsupported protocol version %d", v)
+		}
+		if _, ok := seen[v]; ok {
+			return nil, fmt.Errorf("duplicate protocol version %d", v)
+		}
+		seen[v] = struct{}{}
+	}
+
+	// If the preference order agrees with the plugin's conventional
+	// selection order then we can offer all of the versions at once.
+	newestFirst := slices.IsSortedFunc(c.ProtocolVersions, func(a, b int) int {
+		return b - a
+	})
+	if newestFirst {
+		return [][]int{c.ProtocolVersions}, nil
+	}
+	ret := make([][]int, len(c.ProtocolVersions))
+	for i, v := range c.ProtocolVersions {
+		ret[i] = []int{v}
+	}
+	return ret, nil
+}
+
+// command builds the [exec.Cmd] to use to launch the plugin described by
+// this configuration.
+func (c *GRPCPluginConfig) command() (*exec.Cmd, error) {
+	if c.Executable == "" {
+		return nil, fmt.Errorf("no plugin executable specified")
+	}
+	cmd := exec.Command(c.Executable, c.Args...)
+	cmd.Env = c.Env
+	cmd.Dir = c.Dir
+	cmd.ExtraFiles = c.ExtraFiles
+	if c.PrepareCmd != nil {
+		if err := c.PrepareCmd(cmd); err != nil {
+			return nil, fmt.Errorf("failed to prepare plugin command: %w", err)
+		}
+	}
+	return cmd, nil
+}
@@ -0,0 +1,73 @@
+package tofuprovider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGRPCPluginConfigProtocolVersionOffers(t *testing.T) {
+	tests := map[string]struct {
+		versions []int
+		want     [][]int
+		wantErr  string
+	}{
+		"default": {
+			versions: nil,
+			want:     [][]int{{5, 6}},
+		},
+		"single version": {
+			versions: []int{5},
+			want:     [][]int{{5}},
+		},
+		"newest first": {
+			versions: []int{6, 5},
+			want:     [][]int{{6, 5}},
+		},
+		"oldest first": {
+			versions: []int{5, 6},
+			want:     [][]int{{5}, {6}},
+		},
+		"duplicate": {
+			versions: []int{6, 6},
+			wantErr:  "duplicate protocol version 6",
+		},
+		"duplicate after others": {
+			versions: []int{6, 5, 6},
+			wantErr:  "duplicate protocol version 6",
+		},
+		"unsupported": {
+			versions: []int{6, 4},
+			wantErr:  "unsupported protocol version 4",
+		},
+		"unsupported future version": {
+			versions: []int{7},
+			wantErr:  "unsupported protocol version 7",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := &GRPCPluginConfig{
+				Executable:       "placeholder",
+				ProtocolVersions: test.versions,
+			}
+			got, err := config.protocolVersionOffers()
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error containing %q", test.wantErr)
+				}
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
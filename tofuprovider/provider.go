@@ -18,6 +18,21 @@ import (
 // sent to the running provider plugin, regardless of the specific execution
 // model used for the provider.
 type Provider interface {
+	// GetMetadata requests a summary of the features the provider offers,
+	// listing only the names of each resource type and function without
+	// their full schemas.
+	//
+	// The response includes a [ServerCapabilities] object. If its
+	// GetProviderSchemaIsOptional method returns false then the caller must
+	// still call GetProviderSchema before calling any other method. Use
+	// [NewSchemaCache] to take advantage of this operation while also
+	// respecting that rule.
+	//
+	// Providers that predate this operation return an error that causes
+	// [providerops.IsUnimplementedErr] to return true, in which case callers
+	// should fall back to using GetProviderSchema.
+	GetMetadata(ctx context.Context, req *providerops.GetMetadataRequest) (providerops.GetMetadataResponse, error)
+
 	// GetProviderSchema requests the full provider schema, as a single
 	// large object. A successful result describes all features that the
 	// provider offers, and how clients are expected to interact with those
@@ -30,6 +45,15 @@ type Provider interface {
 	// This method should be called before calling [ConfigureProvider].
 	GetProviderSchema(ctx context.Context, req *providerops.GetProviderSchemaRequest) (providerops.GetProviderSchemaResponse, error)
 
+	// GetResourceIdentitySchemas requests the identity schema for each
+	// managed resource type that supports resource identity, which callers
+	// need in order to decode identity data returned by other operations.
+	//
+	// Providers that predate resource identity return an error that causes
+	// [providerops.IsUnimplementedErr] to return true, which callers should
+	// treat as the provider not supporting resource identity at all.
+	GetResourceIdentitySchemas(ctx context.Context, req *providerops.GetResourceIdentitySchemasRequest) (providerops.GetResourceIdentitySchemasResponse, error)
+
 	// ValidateProviderConfig tests whether a given provider configuration
 	// object is acceptable per the provider's internally-implemented
 	// validation rules.
@@ -66,6 +90,16 @@ type Provider interface {
 	// schema.
 	UpgradeManagedResourceState(ctx context.Context, req *providerops.UpgradeManagedResourceStateRequest) (providerops.UpgradeManagedResourceStateResponse, error)
 
+	// UpgradeManagedResourceIdentity is like UpgradeManagedResourceState
+	// but for the resource identity data previously saved for a managed
+	// resource instance, preparing it to suit the current identity schema
+	// of its resource type.
+	//
+	// Resource identity schemas are versioned independently of the main
+	// resource type schema, so callers must track the identity version
+	// separately from the schema version.
+	UpgradeManagedResourceIdentity(ctx context.Context, req *providerops.UpgradeManagedResourceIdentityRequest) (providerops.UpgradeManagedResourceIdentityResponse, error)
+
 	// ReadManagedResource trades a previously-saved state object of a
 	// managed resource type for a new object updated to match the current
 	// configuration of the remote object.
@@ -106,6 +140,16 @@ type Provider interface {
 	// complete the action. (This functionality is sometimes used when the
 	// source provider is no longer usable for some reason, such as if it's
 	// deprecated has no releases available for the current platform.)
+	//
+	// Only some providers support this operation. If an earlier
+	// GetProviderSchema or GetMetadata response from this provider reported
+	// capabilities where
+	// [providerops.ServerCapabilities.CanMoveManagedResourceState] returns
+	// false then this method returns [providerops.UnsupportedOperationError]
+	// without contacting the provider. If neither operation has been called
+	// yet then the request is sent to the provider regardless, and a provider
+	// that doesn't support it returns an error that causes
+	// [providerops.IsUnimplementedErr] to return true.
 	MoveManagedResourceState(ctx context.Context, req *providerops.MoveManagedResourceStateRequest) (providerops.MoveManagedResourceStateResponse, error)
 
 	// ValidateDataResourceConfig tests whether a given data resource
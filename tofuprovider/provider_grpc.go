@@ -2,12 +2,22 @@ package tofuprovider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.rpcplugin.org/rpcplugin"
+	"google.golang.org/grpc"
 
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
 	"github.com/opentofu/provider-client/tofuprovider/internal/tf5"
 	"github.com/opentofu/provider-client/tofuprovider/internal/tf6"
 	"github.com/opentofu/provider-client/tofuprovider/providertrace"
@@ -21,7 +31,7 @@ import (
 )
 
 // GRPCPluginProvider represents a running provider plugin that was started by
-// [StartRPCPlugin].
+// [StartGRPCPlugin] or connected to using [ConnectGRPCPlugin].
 type GRPCPluginProvider interface {
 	// GRPCPluginProvider is a subtype of [Provider], which represents the
 	// methods supported by all providers regardless of underlying execution
@@ -58,11 +68,68 @@ type GRPCPluginProvider interface {
 	// abstraction altogether.
 	ClientProxy() any
 
+	// Shutdown terminates the provider in a series of increasingly-forceful
+	// stages, for situations where the provider might not respond promptly
+	// to being closed, such as when it's waiting for a remote API that has
+	// stopped responding.
+	//
+	// First Shutdown calls [Provider.GracefulStop] and waits up to
+	// gracePeriod for any requests in progress to finish. It then sends
+	// SIGTERM to the provider's child process and waits up to gracePeriod
+	// again for it to exit, before finally sending SIGKILL. If ctx is
+	// canceled then Shutdown skips directly to sending SIGKILL. Once the
+	// child process has terminated, Shutdown closes the provider in the same
+	// way as [GRPCPluginProvider.Close].
+	//
+	// The result describes which of those stages terminated the provider.
+	// On platforms that don't support SIGTERM, such as Windows, Shutdown
+	// sends SIGKILL immediately after the first grace period. On platforms
+	// where [GRPCPluginProvider.Exited] cannot detect the child process
+	// exiting, Shutdown always sends SIGKILL after waiting for both grace
+	// periods and the result is [ShutdownUnconfirmed].
+	//
+	// For a provider obtained from [ConnectGRPCPlugin] there is no child
+	// process to terminate, so Shutdown only stops the provider gracefully,
+	// waits for requests to finish, and then disconnects.
+	Shutdown(ctx context.Context, gracePeriod time.Duration) (ShutdownStage, error)
+
+	// Exited returns a channel that is closed once the provider's child
+	// process has exited, whether due to calling [GRPCPluginProvider.Close]
+	// or because the provider exited unexpectedly.
+	//
+	// Detecting unexpected exit is supported on Linux (except on MIPS),
+	// macOS, the BSDs, and Windows, and on all platforms for a provider
+	// launched with [GRPCPluginConfig.AutoMTLS] enabled. Otherwise the
+	// channel is closed only once Close has been called.
+	// For a provider obtained from [ConnectGRPCPlugin] there is no child
+	// process, and so the result is a nil channel that is never closed.
+	//
+	// If a request fails because the child process exited unexpectedly then
+	// the method making the request returns a
+	// [providerops.ProviderCrashedError].
+	Exited() <-chan struct{}
+
+	// ExitStatus returns the exit code of the provider's child process and
+	// true if it has exited, or false if it's still running. The exit code
+	// is -1 if the process was terminated by a signal or if its exit code
+	// could not be determined.
+	//
+	// ExitStatus always returns false for a provider obtained from
+	// [ConnectGRPCPlugin].
+	ExitStatus() (int, bool)
+
 	// Close terminates the child process representing the provider.
 	//
-	// After calling this function, the client object enters an invalid state
-	// where all other methods have unspecified behavior. However, it's
-	// acceptable to call close multiple times, with subsequent calls having
+	// For a provider obtained from [ConnectGRPCPlugin], Close instead only
+	// closes the connection to the provider and leaves it running.
+	//
+	// Any requests still in progress when Close is called are canceled, and
+	// Close waits for them to return before closing the connection. Those
+	// requests, and any requests made after calling Close, fail with
+	// [ErrProviderClosed].
+	//
+	// It's safe to call Close concurrently with other methods, and it's
+	// acceptable to call Close multiple times, with subsequent calls having
 	// no effect.
 	Close() error
 
@@ -72,6 +139,47 @@ type GRPCPluginProvider interface {
 	common.Sealed
 }
 
+// ErrProviderClosed is the error returned by methods of [GRPCPluginProvider]
+// and [GRPCPluginProvisioner] that make requests to a plugin that has been
+// closed, or that was closed while the request was in progress.
+var ErrProviderClosed = pluginclient.ErrClosed
+
+// ShutdownStage describes which stage of [GRPCPluginProvider.Shutdown]
+// terminated a provider.
+type ShutdownStage = pluginclient.ShutdownStage
+
+const (
+	// ShutdownExited means that the provider's child process exited without
+	// being sent any signals, such as because it had already crashed.
+	ShutdownExited = pluginclient.ShutdownExited
+
+	// ShutdownTerminated means that the provider's child process exited
+	// after being sent SIGTERM.
+	ShutdownTerminated = pluginclient.ShutdownTerminated
+
+	// ShutdownKilled means that the provider's child process did not exit
+	// within the grace period after being sent SIGTERM, or could not be sent
+	// SIGTERM at all, and so it was sent SIGKILL.
+	ShutdownKilled = pluginclient.ShutdownKilled
+
+	// ShutdownDisconnected means that the provider is not running as a
+	// child process, and so shutting it down only closed the connection.
+	ShutdownDisconnected = pluginclient.ShutdownDisconnected
+
+	// ShutdownUnconfirmed means that the provider's child process was sent
+	// SIGKILL after both grace periods because [GRPCPluginProvider.Exited]
+	// cannot detect it exiting on this platform, and so it's unknown which
+	// stage terminated it.
+	ShutdownUnconfirmed = pluginclient.ShutdownUnconfirmed
+)
+
+// grpcPluginHandshake is the handshake configuration shared by all of the
+// "gRPC-style" plugin types, including both providers and provisioners.
+var grpcPluginHandshake = rpcplugin.HandshakeConfig{
+	CookieKey:   "TF_PLUGIN_MAGIC_COOKIE",
+	CookieValue: "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2",
+}
+
 // StartGRPCPlugin executes the given command line, expecting it to behave
 // as a "gRPC-style" provider plugin, and returns a [GRPCPluginProvider] object
 // representing it.
@@ -92,41 +200,229 @@ type GRPCPluginProvider interface {
 // waiting to receive provider commands. Be sure to call Close on the returned
 // object when you no longer need the provider, so that the child process
 // can be terminated.
+//
+// Use [StartGRPCPluginWithConfig] instead to customize how the child process
+// is launched.
 func StartGRPCPlugin(ctx context.Context, exe string, args ...string) (GRPCPluginProvider, error) {
+	return StartGRPCPluginWithConfig(ctx, &GRPCPluginConfig{
+		Executable: exe,
+		Args:       args,
+	})
+}
+
+// StartGRPCPluginWithConfig is like [StartGRPCPlugin] but allows the caller
+// to customize how the child process is launched, such as by overriding its
+// environment variables or working directory.
+//
+// The plugin handshake behaves in the same way as for [StartGRPCPlugin]
+// regardless of the given configuration, but the configuration can restrict
+// which protocol versions are acceptable, as described for
+// [GRPCPluginConfig.ProtocolVersions]. Use
+// [GRPCPluginProvider.ProtocolMajorVersion] on the result to find out which
+// version was negotiated.
+func StartGRPCPluginWithConfig(ctx context.Context, config *GRPCPluginConfig) (GRPCPluginProvider, error) {
+	offers, err := config.protocolVersionOffers()
+	if err != nil {
+		return nil, err
+	}
+
+	var versionErr pluginclient.ProtocolVersionError
+	for _, versions := range offers {
+		provider, err := startGRPCPlugin(ctx, config, versions)
+		if !errors.As(err, &versionErr) {
+			// Only a protocol version mismatch is worth trying again with
+			// the next offer. Any other failure is likely to recur.
+			return provider, err
+		}
+	}
+	return nil, fmt.Errorf("provider plugin selected protocol version %d, which is not one of the allowed versions %s", versionErr.Selected, formatProtocolVersions(slices.Concat(offers...)))
+}
+
+// startGRPCPlugin is the main implementation of [StartGRPCPluginWithConfig],
+// launching the plugin once and offering only the given protocol versions
+// during negotiation.
+//
+// If the plugin selects a version that wasn't offered then the error wraps
+// a [pluginclient.ProtocolVersionError], regardless of launch method.
+func startGRPCPlugin(ctx context.Context, config *GRPCPluginConfig, versions []int) (GRPCPluginProvider, error) {
 	tracer := providertrace.TracerFromContext(ctx)
 
-	plugin, err := rpcplugin.New(ctx, &rpcplugin.ClientConfig{
-		Handshake: rpcplugin.HandshakeConfig{
-			CookieKey:   "TF_PLUGIN_MAGIC_COOKIE",
-			CookieValue: "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2",
-		},
-		Cmd:    exec.Command(exe, args...),
-		Stderr: tracer.ChildStderr,
-		ProtoVersions: map[int]rpcplugin.ClientVersion{
-			5: tf5.PluginClient{}, // clientProxy is tfplugin5.ProviderClient
-			6: tf6.PluginClient{}, // clientProxy is tfplugin6.ProviderClient
-		},
+	cmd, err := config.command()
+	if err != nil {
+		return nil, err
+	}
+
+	setChildLogLevel(cmd, tracer)
+	tracePluginStart(ctx, tracer, cmd)
+	if config.AutoMTLS {
+		plugin, protoVersion, conn, err := pluginclient.StartAutoMTLS(ctx, &pluginclient.AutoMTLSConfig{
+			Cmd:           cmd,
+			CookieKey:     grpcPluginHandshake.CookieKey,
+			CookieValue:   grpcPluginHandshake.CookieValue,
+			ProtoVersions: versions,
+			Stderr:        newStderrTail(tracer),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to launch provider plugin allowing protocol versions %s: %w", formatProtocolVersions(versions), err)
+		}
+		plugin.SetTimeoutPolicy(config.TimeoutPolicy)
+		if config.Record != nil {
+			plugin.SetRecorder(pluginclient.NewRecorder(config.Record, protoVersion))
+		}
+		traceHandshakeComplete(ctx, tracer, protoVersion, cmd)
+		return newGRPCPluginProvider(ctx, protoVersion, plugin, conn)
+	}
+
+	protoVersions := make(map[int]rpcplugin.ClientVersion, len(versions))
+	for _, v := range versions {
+		protoVersions[v] = grpcProviderProtoVersions[v]
+	}
+
+	stderr := newStderrTail(tracer)
+	rpcPlugin, err := rpcplugin.New(ctx, &rpcplugin.ClientConfig{
+		Handshake:     grpcPluginHandshake,
+		Cmd:           cmd,
+		Stderr:        stderr,
+		ProtoVersions: protoVersions,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to launch provider plugin: %s", err)
+		return nil, fmt.Errorf("failed to launch provider plugin allowing protocol versions %s: %w", formatProtocolVersions(versions), rpcpluginLaunchError(err, versions))
 	}
+	plugin := pluginclient.NewChildProcess(rpcPlugin, cmd.Process, stderr)
+	plugin.SetTimeoutPolicy(config.TimeoutPolicy)
 
 	// If plugin init and handshake is successful then clientProxy is
-	// of the type described in the comments associated with the
-	// matching rpcplugin.ClientConfig.ProtoVersions element above,
-	// for returned protoVersion.
-	protoVersion, clientProxy, err := plugin.Client(ctx)
+	// the *grpc.ClientConn returned by grpcConnClientVersion.
+	protoVersion, clientProxy, err := rpcPlugin.Client(ctx)
 	if err != nil {
 		plugin.Close()
-		return nil, fmt.Errorf("failed to create plugin client: %s", err)
+		return nil, fmt.Errorf("failed to create plugin client: %w", rpcpluginLaunchError(err, versions))
+	}
+	if config.Record != nil {
+		plugin.SetRecorder(pluginclient.NewRecorder(config.Record, protoVersion))
+	}
+	traceHandshakeComplete(ctx, tracer, protoVersion, cmd)
+
+	return newGRPCPluginProvider(ctx, protoVersion, plugin, clientProxy.(*grpc.ClientConn))
+}
+
+// newStderrTail returns the writer to use for the stderr stream of a new
+// plugin child process, based on the settings in the given tracer.
+func newStderrTail(tracer *providertrace.Tracer) *pluginclient.StderrTail {
+	var logs io.WriteCloser
+	if tracer.ChildLog != nil {
+		logs = tracer.ChildLog.Writer()
+	}
+	return pluginclient.NewStderrTail(tracer.ChildStderr, logs)
+}
+
+// setChildLogLevel modifies the environment of the given command to request
+// the log level given in the tracer's log sink, if any.
+//
+// Any TF_LOG variable already in the command's environment takes priority.
+func setChildLogLevel(cmd *exec.Cmd, tracer *providertrace.Tracer) {
+	if tracer.ChildLog == nil || tracer.ChildLog.Level == "" {
+		return
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// When the same variable appears more than once, the last entry takes
+	// priority, so we put ours first.
+	level := strings.ToUpper(string(tracer.ChildLog.Level))
+	cmd.Env = append([]string{"TF_LOG=" + level}, env...)
+}
+
+// tracePluginStart notifies the given tracer, if it's interested, that we're
+// about to launch a plugin using the given command.
+func tracePluginStart(ctx context.Context, tracer *providertrace.Tracer, cmd *exec.Cmd) {
+	if tracer.PluginStart == nil {
+		return
 	}
+	tracer.PluginStart(ctx, &providertrace.PluginStartInfo{
+		Executable: cmd.Path,
+		Args:       cmd.Args[1:],
+	})
+}
 
+// traceHandshakeComplete notifies the given tracer, if it's interested, that
+// the plugin launched using the given command has completed its handshake.
+func traceHandshakeComplete(ctx context.Context, tracer *providertrace.Tracer, protoVersion int, cmd *exec.Cmd) {
+	if tracer.HandshakeComplete == nil {
+		return
+	}
+	tracer.HandshakeComplete(ctx, &providertrace.HandshakeInfo{
+		ProtocolVersion: protoVersion,
+		Pid:             cmd.Process.Pid,
+	})
+}
+
+// grpcProviderProtoVersions describes the protocol major versions that this
+// library supports for "gRPC-style" provider plugins.
+var grpcProviderProtoVersions = map[int]rpcplugin.ClientVersion{
+	5: grpcConnClientVersion{},
+	6: grpcConnClientVersion{},
+}
+
+// rpcpluginVersionPattern matches the part of an rpcplugin error message that
+// reports the protocol version a plugin selected during its handshake.
+var rpcpluginVersionPattern = regexp.MustCompile(`protocol version (\d+)`)
+
+// rpcpluginLaunchError returns the given error from rpcplugin, unless it
+// reports that the plugin selected a protocol version other than the offered
+// versions, in which case it returns a [pluginclient.ProtocolVersionError]
+// describing that instead, wrapping the original error.
+//
+// rpcplugin doesn't have a distinct error type for that situation, so we
+// recognize it by a version number in the message that we didn't offer.
+func rpcpluginLaunchError(err error, offered []int) error {
+	match := rpcpluginVersionPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	selected, convErr := strconv.Atoi(match[1])
+	if convErr != nil || slices.Contains(offered, selected) {
+		return err
+	}
+	return pluginclient.ProtocolVersionError{
+		Selected: selected,
+		Offered:  offered,
+		Err:      err,
+	}
+}
+
+// formatProtocolVersions returns a comma-separated list of the given protocol
+// versions, for use in error messages.
+func formatProtocolVersions(versions []int) string {
+	strs := make([]string, len(versions))
+	for i, v := range versions {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// grpcConnClientVersion is an adapter used with rpcplugin to obtain the
+// underlying gRPC connection to a plugin, so that we can then construct the
+// appropriate client proxy for the negotiated protocol version ourselves.
+type grpcConnClientVersion struct{}
+
+func (c grpcConnClientVersion) ClientProxy(ctx context.Context, conn *grpc.ClientConn) (any, error) {
+	return conn, nil
+}
+
+// newGRPCPluginProvider returns the appropriate [GRPCPluginProvider]
+// implementation for the given protocol major version, making requests to
+// the given plugin using the given connection.
+//
+// protoVersion must be one of the keys of grpcProviderProtoVersions.
+func newGRPCPluginProvider(ctx context.Context, protoVersion int, plugin *pluginclient.Plugin, conn grpc.ClientConnInterface) (GRPCPluginProvider, error) {
 	var ret GRPCPluginProvider
 	switch protoVersion {
 	case 5:
 		// These extra steps are to avoid returning a "typed nil" if
 		// NewProvider returns (*tf6.Provider)(nil).
-		impl, err := tf5.NewProvider(ctx, plugin, clientProxy)
+		impl, err := tf5.NewProvider(ctx, plugin, conn)
 		if impl != nil {
 			ret = impl
 		}
@@ -134,14 +430,14 @@ func StartGRPCPlugin(ctx context.Context, exe string, args ...string) (GRPCPlugi
 	case 6:
 		// These extra steps are to avoid returning a "typed nil" if
 		// NewProvider returns (*tf6.Provider)(nil).
-		impl, err := tf6.NewProvider(ctx, plugin, clientProxy)
+		impl, err := tf6.NewProvider(ctx, plugin, conn)
 		if impl != nil {
 			ret = impl
 		}
 		return ret, err
 	default:
-		// Should not be possible to get here because the above cases cover
-		// all of the versions we listed in ProtoVersions; rpcplugin bug?
+		// Should not be possible to get here because callers should only
+		// use the versions listed in grpcProviderProtoVersions; rpcplugin bug?
 		panic(fmt.Sprintf("unsupported protocol version %d", protoVersion))
 	}
 }
@@ -0,0 +1,31 @@
+package tofuprovider
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+)
+
+// NewGRPCProvider returns a [Provider] that makes requests using the given
+// gRPC connection, which must be connected to a server implementing the
+// provider service for the given protocol major version.
+//
+// Unlike [StartGRPCPlugin] and [ConnectGRPCPlugin], this function does not
+// manage the connection at all: the caller remains responsible for closing
+// the connection once the provider is no longer needed. This is intended for
+// situations where the caller already has a gRPC connection from elsewhere,
+// such as when running a provider server in the same process using
+// [google.golang.org/grpc/test/bufconn].
+//
+// This function currently supports protocol major versions 5 and 6.
+func NewGRPCProvider(ctx context.Context, conn grpc.ClientConnInterface, protoVersion int) (Provider, error) {
+	if _, ok := grpcProviderProtoVersions[protoVersion]; !ok {
+		return nil, fmt.Errorf("unsupported protocol version %d", protoVersion)
+	}
+
+	// The plugin has nothing to close, because the caller owns conn.
+	return newGRPCPluginProvider(ctx, protoVersion, pluginclient.New(nil), conn)
+}
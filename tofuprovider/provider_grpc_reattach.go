@@ -0,0 +1,136 @@
+package tofuprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+)
+
+// ConnectGRPCPlugin connects to a "gRPC-style" provider plugin that is already
+// running and listening at the given network address, and returns a
+// [GRPCPluginProvider] object representing it.
+//
+// This is intended for situations where a provider is being run separately
+// from the client, such as when a provider developer is running a provider
+// under a debugger. It's the equivalent of the "reattach" mechanism that
+// OpenTofu supports through the TF_REATTACH_PROVIDERS environment variable;
+// use [ParseReattachProviders] to parse the values of that variable.
+//
+// There is no plugin handshake in this mode, so the caller must specify which
+// protocol major version the provider is using. This function currently
+// supports protocol major versions 5 and 6.
+//
+// Calling Close on the returned object only closes the connection to the
+// provider. The provider itself remains running, because it's owned by
+// whatever process originally started it.
+func ConnectGRPCPlugin(ctx context.Context, addr net.Addr, protoVersion int) (GRPCPluginProvider, error) {
+	if _, ok := grpcProviderProtoVersions[protoVersion]; !ok {
+		return nil, fmt.Errorf("unsupported protocol version %d", protoVersion)
+	}
+
+	conn, err := pluginclient.Dial(addr, insecure.NewCredentials())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to provider plugin: %s", err)
+	}
+
+	return newGRPCPluginProvider(ctx, protoVersion, pluginclient.New(conn), conn)
+}
+
+// ReattachConfig describes how to connect to a single provider plugin that is
+// already running, as described by one element of the TF_REATTACH_PROVIDERS
+// environment variable.
+//
+// Use [ConnectGRPCPlugin] with the Addr and ProtocolVersion fields to connect
+// to the described provider.
+type ReattachConfig struct {
+	// Protocol is the name of the plugin protocol the provider uses. This
+	// library supports only the "grpc" protocol.
+	Protocol string
+
+	// ProtocolVersion is the protocol major version the provider uses.
+	ProtocolVersion int
+
+	// Pid is the process ID of the running provider.
+	Pid int
+
+	// Test is true if the process that started the provider is expecting
+	// to manage the provider's lifecycle itself, rather than the provider
+	// being terminated by its client.
+	Test bool
+
+	// Addr is the network address where the provider is listening.
+	Addr net.Addr
+}
+
+// ParseReattachProviders parses the given JSON source, which must be in the
+// format used by OpenTofu's TF_REATTACH_PROVIDERS environment variable, and
+// returns a map from provider source address to [ReattachConfig].
+//
+// This function does not check whether the provider source addresses used
+// as map keys are valid, since that's a concern for the caller.
+func ParseReattachProviders(src []byte) (map[string]ReattachConfig, error) {
+	type reattachConfigJSON struct {
+		Protocol        string
+		ProtocolVersion int
+		Pid             int
+		Test            bool
+		Addr            struct {
+			Network string
+			String  string
+		}
+	}
+	var raw map[string]reattachConfigJSON
+	err := json.Unmarshal(src, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reattach providers JSON: %w", err)
+	}
+
+	ret := make(map[string]ReattachConfig, len(raw))
+	for providerAddr, rawConfig := range raw {
+		protocol := rawConfig.Protocol
+		if protocol == "" {
+			protocol = "grpc" // older reattach configurations did not include the protocol
+		}
+		if protocol != "grpc" {
+			return nil, fmt.Errorf("invalid reattach configuration for %q: unsupported protocol %q", providerAddr, protocol)
+		}
+		protoVersion := rawConfig.ProtocolVersion
+		if protoVersion == 0 {
+			protoVersion = 5 // older reattach configurations did not include the protocol version
+		}
+		if rawConfig.Addr.Network == "" || rawConfig.Addr.String == "" {
+			return nil, fmt.Errorf("invalid reattach configuration for %q: missing network address", providerAddr)
+		}
+		ret[providerAddr] = ReattachConfig{
+			Protocol:        protocol,
+			ProtocolVersion: protoVersion,
+			Pid:             rawConfig.Pid,
+			Test:            rawConfig.Test,
+			Addr: reattachAddr{
+				network: rawConfig.Addr.Network,
+				addr:    rawConfig.Addr.String,
+			},
+		}
+	}
+	return ret, nil
+}
+
+// reattachAddr is our implementation of [net.Addr] for addresses parsed by
+// [ParseReattachProviders].
+type reattachAddr struct {
+	network string
+	addr    string
+}
+
+func (a reattachAddr) Network() string {
+	return a.network
+}
+
+func (a reattachAddr) String() string {
+	return a.addr
+}
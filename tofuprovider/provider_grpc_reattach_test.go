@@ -0,0 +1,180 @@
+package tofuprovider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReattachProviders(t *testing.T) {
+	type wantConfig struct {
+		Protocol        string
+		ProtocolVersion int
+		Pid             int
+		Test            bool
+		Network, Addr   string
+	}
+	tests := map[string]struct {
+		input   string
+		want    map[string]wantConfig
+		wantErr string
+	}{
+		"unix socket": {
+			input: `{
+				"registry.opentofu.org/hashicorp/aws": {
+					"Protocol": "grpc",
+					"ProtocolVersion": 5,
+					"Pid": 1234,
+					"Test": true,
+					"Addr": {"Network": "unix", "String": "/tmp/plugin123"}
+				}
+			}`,
+			want: map[string]wantConfig{
+				"registry.opentofu.org/hashicorp/aws": {
+					Protocol:        "grpc",
+					ProtocolVersion: 5,
+					Pid:             1234,
+					Test:            true,
+					Network:         "unix",
+					Addr:            "/tmp/plugin123",
+				},
+			},
+		},
+		"tcp": {
+			input: `{
+				"example.com/foo/bar": {
+					"Protocol": "grpc",
+					"ProtocolVersion": 6,
+					"Pid": 42,
+					"Addr": {"Network": "tcp", "String": "127.0.0.1:5000"}
+				}
+			}`,
+			want: map[string]wantConfig{
+				"example.com/foo/bar": {
+					Protocol:        "grpc",
+					ProtocolVersion: 6,
+					Pid:             42,
+					Network:         "tcp",
+					Addr:            "127.0.0.1:5000",
+				},
+			},
+		},
+		"legacy defaults": {
+			input: `{
+				"example.com/foo/bar": {
+					"Pid": 42,
+					"Addr": {"Network": "unix", "String": "/tmp/plugin123"}
+				}
+			}`,
+			want: map[string]wantConfig{
+				"example.com/foo/bar": {
+					Protocol:        "grpc",
+					ProtocolVersion: 5,
+					Pid:             42,
+					Network:         "unix",
+					Addr:            "/tmp/plugin123",
+				},
+			},
+		},
+		"multiple providers": {
+			input: `{
+				"example.com/foo/a": {
+					"ProtocolVersion": 6,
+					"Addr": {"Network": "unix", "String": "/tmp/a"}
+				},
+				"example.com/foo/b": {
+					"ProtocolVersion": 5,
+					"Addr": {"Network": "tcp", "String": "127.0.0.1:5001"}
+				}
+			}`,
+			want: map[string]wantConfig{
+				"example.com/foo/a": {
+					Protocol:        "grpc",
+					ProtocolVersion: 6,
+					Network:         "unix",
+					Addr:            "/tmp/a",
+				},
+				"example.com/foo/b": {
+					Protocol:        "grpc",
+					ProtocolVersion: 5,
+					Network:         "tcp",
+					Addr:            "127.0.0.1:5001",
+				},
+			},
+		},
+		"empty object": {
+			input: `{}`,
+			want:  map[string]wantConfig{},
+		},
+		"non-grpc protocol": {
+			input: `{
+				"example.com/foo/bar": {
+					"Protocol": "netrpc",
+					"ProtocolVersion": 4,
+					"Addr": {"Network": "unix", "String": "/tmp/plugin123"}
+				}
+			}`,
+			wantErr: `invalid reattach configuration for "example.com/foo/bar": unsupported protocol "netrpc"`,
+		},
+		"missing address": {
+			input: `{
+				"example.com/foo/bar": {
+					"Protocol": "grpc",
+					"ProtocolVersion": 5
+				}
+			}`,
+			wantErr: `invalid reattach configuration for "example.com/foo/bar": missing network address`,
+		},
+		"missing address string": {
+			input: `{
+				"example.com/foo/bar": {
+					"Addr": {"Network": "tcp"}
+				}
+			}`,
+			wantErr: `invalid reattach configuration for "example.com/foo/bar": missing network address`,
+		},
+		"invalid JSON": {
+			input:   `{"example.com/foo/bar": `,
+			wantErr: `invalid reattach providers JSON`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReattachProviders([]byte(test.input))
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error containing %q", test.wantErr)
+				}
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("wrong number of providers: got %d, want %d", len(got), len(test.want))
+			}
+			for addr, want := range test.want {
+				config, ok := got[addr]
+				if !ok {
+					t.Errorf("missing result for %q", addr)
+					continue
+				}
+				gotConfig := wantConfig{
+					Protocol:        config.Protocol,
+					ProtocolVersion: config.ProtocolVersion,
+					Pid:             config.Pid,
+					Test:            config.Test,
+					Network:         config.Addr.Network(),
+					Addr:            config.Addr.String(),
+				}
+				if gotConfig != want {
+					t.Errorf("wrong result for %q\ngot:  %#v\nwant: %#v", addr, gotConfig, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package tofuprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+)
+
+// helperEnv is the environment variable that tells [TestHelperProcess] to
+// behave as a plugin for another test, and which handshake line to write.
+const helperEnv = "TOFUPROVIDER_TEST_HELPER_HANDSHAKE"
+
+// TestHelperProcess is not a real test. It's run in a child process by other
+// tests to stand in for a plugin that writes the handshake line given in
+// [helperEnv] and then exits immediately, which is sufficient for tests of
+// failed handshakes.
+func TestHelperProcess(t *testing.T) {
+	handshake := os.Getenv(helperEnv)
+	if handshake == "" {
+		return // running as a normal test
+	}
+	fmt.Println(handshake)
+	os.Exit(0)
+}
+
+func TestStartGRPCPluginWithConfigVersionMismatch(t *testing.T) {
+	launches := 0
+	_, err := StartGRPCPluginWithConfig(context.Background(), &GRPCPluginConfig{
+		Executable: os.Args[0],
+		Args:       []string{"-test.run=^TestHelperProcess$"},
+		Env:        append(os.Environ(), helperEnv+"=1|4|unix|/nonexistent|grpc"),
+		PrepareCmd: func(cmd *exec.Cmd) error {
+			launches++
+			return nil
+		},
+		// Oldest first requires a separate launch for each version.
+		ProtocolVersions: []int{5, 6},
+		AutoMTLS:         true,
+	})
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if got, want := err.Error(), "provider plugin selected protocol version 4, which is not one of the allowed versions 5, 6"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+	if launches != 2 {
+		t.Errorf("plugin launched %d times; want 2", launches)
+	}
+}
+
+func TestStartGRPCPluginWithConfigLaunchError(t *testing.T) {
+	launches := 0
+	_, err := StartGRPCPluginWithConfig(context.Background(), &GRPCPluginConfig{
+		Executable: os.Args[0],
+		PrepareCmd: func(cmd *exec.Cmd) error {
+			launches++
+			return errors.New("placeholder failure")
+		},
+		ProtocolVersions: []int{5, 6},
+	})
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+	if got, want := err.Error(), "failed to prepare plugin command: placeholder failure"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+	if launches != 1 {
+		t.Errorf("plugin launched %d times; want 1", launches)
+	}
+}
+
+func TestRPCPluginLaunchError(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		wantSelected int // zero if the error should be returned unchanged
+	}{
+		"unoffered version": {
+			err:          errors.New("plugin selected unsupported protocol version 4"),
+			wantSelected: 4,
+		},
+		"offered version": {
+			err: errors.New("failed to start plugin using protocol version 5"),
+		},
+		"unrelated": {
+			err: errors.New("plugin exited before completing handshake"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := rpcpluginLaunchError(test.err, []int{5, 6})
+			if !errors.Is(got, test.err) {
+				t.Errorf("result does not wrap the original error: %s", got)
+			}
+			var versionErr pluginclient.ProtocolVersionError
+			isVersionErr := errors.As(got, &versionErr)
+			if test.wantSelected == 0 {
+				if isVersionErr {
+					t.Fatalf("unexpected protocol version error: %s", got)
+				}
+				return
+			}
+			if !isVersionErr {
+				t.Fatalf("not a protocol version error: %s", got)
+			}
+			if versionErr.Selected != test.wantSelected {
+				t.Errorf("wrong selected version %d; want %d", versionErr.Selected, test.wantSelected)
+			}
+			if want := "5, 6"; !strings.HasSuffix(got.Error(), want) {
+				t.Errorf("error does not report the offered versions %s: %s", want, got)
+			}
+		})
+	}
+}
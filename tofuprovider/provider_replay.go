@@ -0,0 +1,54 @@
+package tofuprovider
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+)
+
+// ReplayProvider is a [Provider] that responds to requests using a recording,
+// as returned by [NewReplayProvider].
+type ReplayProvider interface {
+	Provider
+
+	// Close returns an error if any of the recorded responses were never
+	// used, which typically means that the code under test made fewer
+	// requests than when the recording was made.
+	//
+	// Any requests made after calling Close fail with [ErrProviderClosed].
+	Close() error
+}
+
+// NewReplayProvider returns a [ReplayProvider] that responds to requests using
+// a recording previously made using [GRPCPluginConfig.Record], without
+// launching or connecting to any plugin.
+//
+// This is intended for deterministic tests of code that uses a provider,
+// which can then run without access to whatever remote system the real
+// provider would interact with.
+//
+// The recording is read fully before this function returns. Each recorded
+// response is used at most once, in response to a request that exactly
+// matches the recorded request. Requests that don't match any remaining
+// recorded response fail with [providerops.UnmatchedRequestError].
+//
+// Call [ReplayProvider.Close] once finished with the provider to check that
+// the whole recording was used.
+//
+// Only providers launched using [StartGRPCPluginWithConfig] can be recorded,
+// by setting [GRPCPluginConfig.Record].
+func NewReplayProvider(ctx context.Context, recording io.Reader) (ReplayProvider, error) {
+	protoVersion, conn, err := pluginclient.ReadCassette(recording)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider recording: %w", err)
+	}
+	if _, ok := grpcProviderProtoVersions[protoVersion]; !ok {
+		return nil, fmt.Errorf("provider recording uses unsupported protocol version %d", protoVersion)
+	}
+
+	// There's no real plugin, so closing only checks that the whole
+	// recording was used.
+	return newGRPCPluginProvider(ctx, protoVersion, pluginclient.New(conn), conn)
+}
@@ -0,0 +1,98 @@
+package tofuprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+)
+
+func TestNewReplayProvider(t *testing.T) {
+	const recording = `{"format":"opentofu-provider-session","version":1,"protocolVersion":6}
+{"method":"/tfplugin6.Provider/GetProviderSchema","request":{},"response":{"dataSourceSchemas":{"test_thing":{"block":{}}},"diagnostics":[{"severity":"WARNING","summary":"recorded warning"}]}}
+`
+	ctx := context.Background()
+	provider, err := NewReplayProvider(ctx, strings.NewReader(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := provider.GetProviderSchema(ctx, &providerops.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []string
+	for diag := range resp.Diagnostics().All() {
+		summaries = append(summaries, diag.Summary())
+	}
+	if len(summaries) != 1 || summaries[0] != "recorded warning" {
+		t.Errorf("wrong diagnostics %q", summaries)
+	}
+	found := false
+	for name := range resp.ProviderSchema().DataResourceTypeSchemas() {
+		found = found || name == "test_thing"
+	}
+	if !found {
+		t.Errorf("replayed schema does not include the recorded data resource type")
+	}
+
+	// The recorded response has already been used.
+	_, err = provider.GetProviderSchema(ctx, &providerops.GetProviderSchemaRequest{})
+	var unmatched providerops.UnmatchedRequestError
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("wrong error %v; want UnmatchedRequestError", err)
+	}
+	if unmatched.Operation != "GetProviderSchema" {
+		t.Errorf("wrong operation %q", unmatched.Operation)
+	}
+	if err := provider.Close(); err != nil {
+		t.Errorf("unexpected error from Close: %s", err)
+	}
+}
+
+func TestNewReplayProviderUnused(t *testing.T) {
+	const recording = `{"format":"opentofu-provider-session","version":1,"protocolVersion":6}
+{"method":"/tfplugin6.Provider/GetProviderSchema","request":{},"response":{}}
+{"method":"/tfplugin6.Provider/ReadDataSource","request":{"typeName":"test_thing"},"response":{}}
+`
+	ctx := context.Background()
+	provider, err := NewReplayProvider(ctx, strings.NewReader(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.GetProviderSchema(ctx, &providerops.GetProviderSchemaRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = provider.Close()
+	if got, want := fmt.Sprint(err), `1 recorded requests were not replayed: ReadDataResource for resource type "test_thing"`; got != want {
+		t.Errorf("wrong error from Close\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestNewReplayProviderInvalid(t *testing.T) {
+	tests := map[string]struct {
+		recording string
+		wantErr   string
+	}{
+		"empty": {
+			recording: "",
+			wantErr:   "invalid provider recording: cassette is empty",
+		},
+		"unsupported protocol version": {
+			recording: `{"format":"opentofu-provider-session","version":1,"protocolVersion":4}` + "\n",
+			wantErr:   "provider recording uses unsupported protocol version 4",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReplayProvider(context.Background(), strings.NewReader(test.recording))
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("wrong error\ngot:  %v\nwant: %s", err, test.wantErr)
+			}
+		})
+	}
+}
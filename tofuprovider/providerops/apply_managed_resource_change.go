@@ -56,7 +56,11 @@ type ApplyManagedResourceChangeRequest struct {
 	// ProviderMetaSchema.
 	ProviderMeta providerschema.DynamicValueIn
 
-	// TODO: PlannedNewIdentity
+	// PlannedNewIdentity is the same value returned from the
+	// PlannedNewIdentity method in the response from PlanManagedResourceChange
+	// that this call is intending to apply, or [providerschema.NoDynamicValue]
+	// if that method returned nil.
+	PlannedNewIdentity providerschema.DynamicValueIn
 }
 
 type ApplyManagedResourceChangeResponse interface {
@@ -94,7 +98,13 @@ type ApplyManagedResourceChangeResponse interface {
 	// provider behavior consistency checks.
 	LegacyTypeSystem() bool
 
-	// TODO: NewIdentity
+	// NewIdentity returns the resource identity of the remote object after
+	// the change was applied, or nil if the provider did not return any
+	// identity data.
+	//
+	// This must be decoded using the type implied by the identity schema of
+	// the resource type, and should be saved alongside the new state.
+	NewIdentity() providerschema.DynamicValueOut
 
 	common.Sealed
 }
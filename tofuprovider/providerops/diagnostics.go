@@ -3,6 +3,8 @@ package providerops
 import (
 	"iter"
 
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
 
 	// For links in documentation comments:
@@ -35,8 +37,17 @@ type Diagnostic interface {
 	Summary() string
 	Detail() string
 
-	// TODO: AttributePath, which has an awkward model as a sequence of
-	// values of three possible types.
+	// AttributePath returns the path to a specific attribute that the
+	// diagnostic relates to, along with true, or a meaningless value along
+	// with false if the diagnostic is not specific to any attribute.
+	//
+	// The path is relative to the top-level object that the request was
+	// about, such as the configuration object in a validation request. The
+	// path can only include attribute and index steps, and index steps use
+	// either string or number keys. If the provider reported a path that
+	// includes some other kind of step then the result is false, as if the
+	// diagnostic were not specific to any attribute.
+	AttributePath() (cty.Path, bool)
 
 	common.Sealed
 }
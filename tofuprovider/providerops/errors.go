@@ -1,6 +1,9 @@
 package providerops
 
 import (
+	"errors"
+	"fmt"
+
 	grpcCodes "google.golang.org/grpc/codes"
 	grpcStatus "google.golang.org/grpc/status"
 )
@@ -21,6 +24,12 @@ func IsUnimplementedErr(err error) bool {
 	switch {
 	case grpcStatus.Code(err) == grpcCodes.Unimplemented:
 		return true
+	case errors.As(err, new(UnsupportedOperationError)):
+		// We also treat our own client-side refusal to call an operation
+		// that the provider has declared it doesn't support as
+		// "unimplemented", since callers will typically want to respond
+		// to both situations in the same way.
+		return true
 
 		// (if we have protocol implementations that are not gRPC-based in
 		// future then we should add additional cases to catch whatever
@@ -30,3 +39,90 @@ func IsUnimplementedErr(err error) bool {
 		return false
 	}
 }
+
+// UnsupportedOperationError is the error type returned by methods of
+// [tofuprovider.Provider] that the client refuses to call because the
+// provider has reported [ServerCapabilities] that don't include support
+// for them.
+//
+// Errors of this type are returned before any request is sent to the
+// provider. Use [errors.As] to detect them, or use [IsUnimplementedErr] to
+// handle them in the same way as a provider that reports that it doesn't
+// implement an operation.
+type UnsupportedOperationError struct {
+	// Operation is the name of the [tofuprovider.Provider] method that
+	// was called.
+	Operation string
+}
+
+func (e UnsupportedOperationError) Error() string {
+	return fmt.Sprintf("provider does not support %s", e.Operation)
+}
+
+// ProviderCrashedError is the error type returned by methods of
+// [tofuprovider.Provider] when a request fails because the provider's child
+// process exited unexpectedly, which typically means that the provider
+// crashed.
+//
+// Use [errors.As] to detect errors of this type.
+//
+// Crashes can be detected on Linux (except on MIPS), macOS, the BSDs, and
+// Windows. On other platforms, a provider launched without
+// [tofuprovider.GRPCPluginConfig.AutoMTLS] enabled is not monitored for
+// exit, and so a crash is reported only as the underlying request error.
+type ProviderCrashedError struct {
+	// ExitCode is the exit code of the provider's child process, or -1 if
+	// it was terminated by a signal or its exit code is unknown.
+	ExitCode int
+
+	// StderrTail is the most recent data the provider wrote to its stderr
+	// stream before exiting, which often includes a description of the
+	// crash such as a Go panic message and stack trace.
+	//
+	// Only a limited amount of data is retained, so this might not include
+	// everything the provider wrote.
+	StderrTail string
+
+	// Err is the error returned by the failed request.
+	Err error
+}
+
+func (e ProviderCrashedError) Error() string {
+	return fmt.Sprintf("provider process exited unexpectedly with exit code %d: %s", e.ExitCode, e.Err)
+}
+
+func (e ProviderCrashedError) Unwrap() error {
+	return e.Err
+}
+
+// UnmatchedRequestError is the error type returned by methods of a
+// [tofuprovider.Provider] that is replaying a recorded session when the
+// recording does not include a request matching the one being made.
+//
+// Use [errors.As] to detect errors of this type. This typically means that
+// the code under test has changed how it uses the provider since the session
+// was recorded, and so the session must be recorded again.
+type UnmatchedRequestError struct {
+	// Operation is the name of the [tofuprovider.Provider] method that was
+	// called.
+	Operation string
+
+	// ResourceType is the name of the resource type that the request relates
+	// to, or an empty string if the operation does not relate to a single
+	// resource type.
+	ResourceType string
+
+	// Request is the JSON representation of the protocol request message that
+	// had no match, in the same form used in the recording.
+	//
+	// This is not included in the error message, because requests can
+	// include sensitive values such as provider credentials.
+	Request string
+}
+
+func (e UnmatchedRequestError) Error() string {
+	if e.ResourceType != "" {
+		return fmt.Sprintf("no recorded response for %s request for resource type %q", e.Operation, e.ResourceType)
+	}
+	return fmt.Sprintf("no recorded response for %s request", e.Operation)
+}
@@ -0,0 +1,59 @@
+package providerops
+
+import (
+	"iter"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+
+	// For links in documentation comments:
+	_ "slices"
+)
+
+type GetMetadataRequest struct {
+	// There are currently no arguments in a metadata request.
+}
+
+type GetMetadataResponse interface {
+	// Diagnostics are any diagnostics included in the provider's response.
+	//
+	// If the result's [Diagnostics.HasErrors] method returns true then
+	// the results of all other methods are unspecified and meaningless.
+	Diagnostics() Diagnostics
+
+	// ServerCapabilities returns an object describing various special
+	// capabilities the provider claims to have, intended for use as part
+	// of client/server capability negotiation.
+	//
+	// Callers MUST respect the server's reported capabilities or else
+	// the provider is likely to malfunction in unexpected ways. In particular,
+	// if [ServerCapabilities.GetProviderSchemaIsOptional] returns false then
+	// the caller must call GetProviderSchema before making any other
+	// request.
+	ServerCapabilities() ServerCapabilities
+
+	// ManagedResourceTypeNames returns an iterable sequence of the names of
+	// all of the managed resource types supported by this provider.
+	//
+	// Use [slices.Collect] with the result if you need a slice of names.
+	ManagedResourceTypeNames() iter.Seq[string]
+
+	// DataResourceTypeNames returns an iterable sequence of the names of
+	// all of the data resource types supported by this provider.
+	//
+	// Use [slices.Collect] with the result if you need a slice of names.
+	DataResourceTypeNames() iter.Seq[string]
+
+	// EphemeralResourceTypeNames returns an iterable sequence of the names of
+	// all of the ephemeral resource types supported by this provider.
+	//
+	// Use [slices.Collect] with the result if you need a slice of names.
+	EphemeralResourceTypeNames() iter.Seq[string]
+
+	// FunctionNames returns an iterable sequence of the names of all of the
+	// "provider-defined functions" supported by this provider.
+	//
+	// Use [slices.Collect] with the result if you need a slice of names.
+	FunctionNames() iter.Seq[string]
+
+	common.Sealed
+}
@@ -0,0 +1,32 @@
+package providerops
+
+import (
+	"iter"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+type GetResourceIdentitySchemasRequest struct {
+	// There are currently no arguments in a resource identity schemas request.
+}
+
+type GetResourceIdentitySchemasResponse interface {
+	// Diagnostics are any diagnostics included in the provider's response.
+	//
+	// If the result's [Diagnostics.HasErrors] method returns true then
+	// the results of all other methods are unspecified and meaningless.
+	Diagnostics() Diagnostics
+
+	// IdentitySchemas returns an iterable sequence of the identity schema
+	// for each managed resource type that supports resource identity.
+	//
+	// The first result in each pair is the unique resource type name that
+	// the schema belongs to. Use [maps.Collect] to gather the result into a
+	// map from name to schema if you expect to need schemas for more than one
+	// resource type. Managed resource types that do not support resource
+	// identity are not included.
+	IdentitySchemas() iter.Seq2[string, providerschema.IdentitySchema]
+
+	common.Sealed
+}
@@ -14,6 +14,8 @@ type ImportManagedResourceStateRequest struct {
 
 	// ID is some sort of unique identifier for the object to be imported,
 	// in a format decided by the provider.
+	//
+	// Callers should populate either ID or Identity, but not both.
 	ID string
 
 	// ClientCapabilities allows the caller to declare that it is capable of
@@ -22,7 +24,13 @@ type ImportManagedResourceStateRequest struct {
 	// by default to avoid confusing older clients.
 	ClientCapabilities *ClientCapabilities
 
-	// TODO: Identity
+	// Identity is a dynamic value representing the resource identity of
+	// the object to be imported, as an alternative to ID for providers that
+	// support importing by identity.
+	//
+	// When populated the value must be of the type implied by the current
+	// identity schema for the given resource type.
+	Identity providerschema.DynamicValueIn
 }
 
 type ImportManagedResourceStateResponse interface {
@@ -60,7 +68,12 @@ type ImportedManagedResource interface {
 	// details on how to use this.
 	ProviderInternal() []byte
 
-	// TODO: Identity
+	// Identity returns the resource identity of the imported object, or nil
+	// if the provider did not return any identity data.
+	//
+	// This must be decoded using the type implied by the identity schema of
+	// the resource type given by ResourceType.
+	Identity() providerschema.DynamicValueOut
 
 	common.Sealed
 }
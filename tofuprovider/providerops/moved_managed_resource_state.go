@@ -40,7 +40,18 @@ type MoveManagedResourceStateRequest struct {
 	// should be converted to.
 	TargetResourceType string
 
-	// TODO: SourceIdentity and SourceIdentitySchemaVersion
+	// SourceIdentityRaw is the raw representation of the source object's
+	// resource identity, if any. Leave this as the zero value of
+	// [providerschema.RawState] if the source object has no identity data.
+	//
+	// As with SourceStateRaw, the client is not expected to decode this
+	// data itself and the provider is responsible for interpreting it.
+	SourceIdentityRaw providerschema.RawState
+
+	// SourceIdentitySchemaVersion is the identity schema version for the
+	// source resource type that was current when the data in
+	// SourceIdentityRaw was created.
+	SourceIdentitySchemaVersion int64
 }
 
 type MoveManagedResourceStateResponse interface {
@@ -64,5 +75,13 @@ type MoveManagedResourceStateResponse interface {
 	// details on how to use this.
 	TargetProviderInternal() []byte
 
+	// TargetIdentity returns the resource identity of the object for the
+	// target resource type, or nil if the provider did not return any
+	// identity data.
+	//
+	// This must be decoded using the type implied by the identity schema of
+	// the target resource type.
+	TargetIdentity() providerschema.DynamicValueOut
+
 	common.Sealed
 }
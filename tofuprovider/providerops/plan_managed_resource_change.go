@@ -1,8 +1,15 @@
 package providerops
 
 import (
+	"iter"
+
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/opentofu/provider-client/tofuprovider/internal/common"
 	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+
+	// For links in documentation comments:
+	_ "slices"
 )
 
 type PlanManagedResourceChangeRequest struct {
@@ -64,7 +71,15 @@ type PlanManagedResourceChangeRequest struct {
 	// by default to avoid confusing older clients.
 	ClientCapabilities *ClientCapabilities
 
-	// TODO: PriorIdentity
+	// PriorIdentity is a dynamic value representing the resource identity
+	// that was returned along with PriorState, if any.
+	//
+	// When populated the value must be of the type implied by the current
+	// identity schema for the given resource type. Leave this set to
+	// [providerschema.NoDynamicValue] when planning to create a new object,
+	// or if the provider has not previously returned any identity data for
+	// this object.
+	PriorIdentity providerschema.DynamicValueIn
 }
 
 type PlanManagedResourceChangeResponse interface {
@@ -90,6 +105,22 @@ type PlanManagedResourceChangeResponse interface {
 	// containing any additional internal data the provider needs to track.
 	PlannedProviderInternal() []byte
 
+	// RequiresReplace returns an iterable sequence of paths to attributes
+	// whose planned changes cannot be applied in-place, and so which require
+	// the remote object to be destroyed and recreated to apply this change.
+	//
+	// Each path is relative to the top-level object described by the
+	// resource type's schema. The result is empty if the provider can apply
+	// the planned change without replacing the object.
+	//
+	// Any path that includes a kind of step that this library doesn't
+	// understand is omitted from the result, rather than being shortened
+	// to refer to a containing object that the provider didn't mention.
+	//
+	// Use [slices.Collect] with the result to gather all of the paths into
+	// a slice, if needed.
+	RequiresReplace() iter.Seq[cty.Path]
+
 	// LegacyTypeSystem returns true if this provider is implemented using
 	// the legacy SDK originally intended for now-obsolete versions of
 	// Terraform, which cannot properly satisfy the requirements of the
@@ -111,7 +142,13 @@ type PlanManagedResourceChangeResponse interface {
 	// potentially be applied.
 	Deferred() Deferred
 
-	// TODO: PlannedNewIdentity
+	// PlannedNewIdentity returns the resource identity the provider expects
+	// the object to have if this change is applied, or nil if the provider
+	// did not return any identity data.
+	//
+	// This must be decoded using the type implied by the identity schema of
+	// the resource type.
+	PlannedNewIdentity() providerschema.DynamicValueOut
 
 	common.Sealed
 }
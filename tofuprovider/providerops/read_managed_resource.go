@@ -40,7 +40,14 @@ type ReadManagedResourceRequest struct {
 	// by default to avoid confusing older clients.
 	ClientCapabilities *ClientCapabilities
 
-	// TODO: CurrentIdentity
+	// CurrentIdentity is a dynamic value representing the resource identity
+	// that was most recently returned for this remote object, if any.
+	//
+	// When populated the value must be of the type implied by the current
+	// identity schema for the given resource type. Leave this set to
+	// [providerschema.NoDynamicValue] if the provider has not previously
+	// returned any identity data for this object.
+	CurrentIdentity providerschema.DynamicValueIn
 }
 
 type ReadManagedResourceResponse interface {
@@ -70,7 +77,12 @@ type ReadManagedResourceResponse interface {
 	// should replace the previous values that were saved in the prior state.
 	Deferred() Deferred
 
-	// TODO: NewIdentity
+	// NewIdentity is the updated resource identity data, or nil if the
+	// provider did not return any identity data.
+	//
+	// This must be decoded using the type implied by the identity schema of
+	// the resource type.
+	NewIdentity() providerschema.DynamicValueOut
 
 	common.Sealed
 }
@@ -0,0 +1,153 @@
+package providerops
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OperationKind is a broad category of provider operations, used to select
+// a default timeout from a [TimeoutPolicy].
+type OperationKind int
+
+const (
+	// OperationValidate represents the operations that validate
+	// configuration, such as ValidateProviderConfig and
+	// ValidateManagedResourceConfig.
+	OperationValidate OperationKind = iota + 1
+
+	// OperationSchema represents the operations that return schema
+	// information: GetMetadata, GetProviderSchema,
+	// GetResourceIdentitySchemas, and GetFunctions.
+	OperationSchema
+
+	// OperationConfigure represents ConfigureProvider.
+	OperationConfigure
+
+	// OperationRead represents the operations that read or transform
+	// existing objects without planning or applying changes: the
+	// ReadManagedResource, ReadDataResource, UpgradeManagedResourceState,
+	// UpgradeManagedResourceIdentity, ImportManagedResourceState, and
+	// MoveManagedResourceState operations, along with the operations for
+	// opening, renewing, and closing ephemeral resources.
+	OperationRead
+
+	// OperationPlan represents PlanManagedResourceChange.
+	OperationPlan
+
+	// OperationApply represents ApplyManagedResourceChange.
+	OperationApply
+
+	// OperationFunction represents CallFunction.
+	OperationFunction
+)
+
+func (k OperationKind) String() string {
+	switch k {
+	case OperationValidate:
+		return "validate"
+	case OperationSchema:
+		return "schema"
+	case OperationConfigure:
+		return "configure"
+	case OperationRead:
+		return "read"
+	case OperationPlan:
+		return "plan"
+	case OperationApply:
+		return "apply"
+	case OperationFunction:
+		return "function"
+	default:
+		return "unknown"
+	}
+}
+
+// TimeoutPolicy describes default timeouts for provider operations, so that
+// an operation that hangs does not block its caller forever even if the
+// caller did not set its own deadline.
+//
+// A policy can be set for all operations on a provider when it's launched,
+// or for individual calls using [ContextWithTimeoutPolicy]. A policy only
+// ever shortens the deadline of an operation: if the caller's context
+// has an earlier deadline then that deadline takes effect as normal.
+//
+// If an operation exceeds the timeout from a policy then it fails with a
+// [TimeoutError]. GracefulStop is not subject to timeout policies.
+type TimeoutPolicy struct {
+	// Timeouts is the default timeout for each kind of operation. Operations
+	// of a kind that's not present in the map have no default timeout.
+	Timeouts map[OperationKind]time.Duration
+
+	// GracefulStopBefore, if positive, causes the client to call the
+	// provider's GracefulStop operation this long before the timeout of an
+	// operation expires, if the operation is still in progress, to give
+	// the provider a chance to return early with a meaningful error rather
+	// than being canceled abruptly.
+	//
+	// GracefulStop asks the provider to stop all of its in-progress
+	// operations, and so this can cause other operations running
+	// concurrently on the same provider to fail too.
+	GracefulStopBefore time.Duration
+}
+
+// Timeout returns the default timeout for the given kind of operation, or
+// zero if there is no default timeout.
+//
+// Timeout can be called on a nil *TimeoutPolicy, always returning zero.
+func (p *TimeoutPolicy) Timeout(kind OperationKind) time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.Timeouts[kind]
+}
+
+// ContextWithTimeoutPolicy returns a new context, child of parent, which
+// carries the given [TimeoutPolicy] for use by any provider operations
+// called with that context.
+//
+// A policy given in the context takes priority over any policy that was
+// set when the provider was launched.
+func ContextWithTimeoutPolicy(parent context.Context, policy *TimeoutPolicy) context.Context {
+	if policy == nil {
+		return parent
+	}
+	return context.WithValue(parent, timeoutPolicyKey(0), policy)
+}
+
+// TimeoutPolicyFromContext returns the policy that was previously associated
+// with the given context (or one of its parents) using
+// [ContextWithTimeoutPolicy], or nil if there is none.
+func TimeoutPolicyFromContext(ctx context.Context) *TimeoutPolicy {
+	policy, _ := ctx.Value(timeoutPolicyKey(0)).(*TimeoutPolicy)
+	return policy
+}
+
+type timeoutPolicyKey int
+
+// TimeoutError is the error type returned by methods of
+// [tofuprovider.Provider] when an operation exceeds the timeout from a
+// [TimeoutPolicy].
+//
+// Use [errors.As] to detect errors of this type. Operations that exceed
+// a deadline set directly by the caller's own context return the usual
+// context error instead.
+type TimeoutError struct {
+	// Operation is the name of the [tofuprovider.Provider] method that
+	// timed out.
+	Operation string
+
+	// Timeout is the timeout from the policy that was exceeded.
+	Timeout time.Duration
+
+	// Err is the error returned by the request that timed out.
+	Err error
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("%s did not complete within %s", e.Operation, e.Timeout)
+}
+
+func (e TimeoutError) Unwrap() error {
+	return e.Err
+}
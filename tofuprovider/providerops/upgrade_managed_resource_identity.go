@@ -0,0 +1,52 @@
+package providerops
+
+import (
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+type UpgradeManagedResourceIdentityRequest struct {
+	// ResourceType is the name of the type of resource the given identity
+	// data was created by.
+	ResourceType string
+
+	// IdentityVersion is the identity schema version for the given resource
+	// type that was current when the provided raw identity data was created.
+	//
+	// This is separate from the schema version used with
+	// UpgradeManagedResourceState, because resource identity schemas are
+	// versioned independently of the main resource type schema. Callers must
+	// save this version number alongside the identity data for use in a
+	// future upgrade request.
+	IdentityVersion int64
+
+	// PrevIdentityRaw is the raw representation of the previously-saved
+	// identity data.
+	//
+	// Clients are not expected to have access to identity schema information
+	// for older versions of a provider and so for this operation the client
+	// skips trying to decode the data itself and instead assumes that the
+	// provider knows how to decode data created by earlier versions of itself.
+	//
+	// Use [providerschema.NewRawState] with identity data returned from some
+	// other provider operation to produce a suitable value to save. Exactly
+	// one of the JSON and Flatmap fields must be populated, although in
+	// practice providers expect only JSON for identity data.
+	PrevIdentityRaw providerschema.RawState
+}
+
+type UpgradeManagedResourceIdentityResponse interface {
+	// Diagnostics are any diagnostics included in the provider's response.
+	//
+	// If the result's [Diagnostics.HasErrors] method returns true then
+	// the results of all other methods are unspecified and meaningless.
+	Diagnostics() Diagnostics
+
+	// UpgradedIdentity is the upgraded identity data.
+	//
+	// This must be decoded using the type implied by the current identity
+	// schema of the resource type.
+	UpgradedIdentity() providerschema.DynamicValueOut
+
+	common.Sealed
+}
@@ -29,6 +29,8 @@ type UpgradeManagedResourceStateRequest struct {
 	// versions of a provider and so for this operation the client skips
 	// trying to decode the data itself and instead assumes that the provider
 	// knows how to decode data created by earlier versions of itself.
+	//
+	// Exactly one of the JSON and Flatmap fields must be populated.
 	PrevStateRaw providerschema.RawState
 }
 
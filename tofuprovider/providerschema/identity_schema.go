@@ -0,0 +1,81 @@
+package providerschema
+
+import (
+	"iter"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+
+	// For links in documentation comments:
+	_ "maps"
+)
+
+// IdentitySchema describes the structure of the resource identity data for
+// a managed resource type.
+//
+// Resource identity is an object that uniquely identifies the remote object
+// associated with a managed resource instance, versioned independently of the
+// resource type's main [Schema]. The implied type of the identity data is an
+// object type whose attributes are the names and types of the attributes
+// returned by [IdentitySchema.Attributes].
+type IdentitySchema interface {
+	// IdentityVersion is the identity schema version number reported by the
+	// provider.
+	//
+	// This is separate from the resource type's [Schema.SchemaVersion], and
+	// is used to drive the separate "identity upgrade" process. Identity data
+	// created under different identity versions must never be compared
+	// directly.
+	IdentityVersion() int64
+
+	// Attributes returns an iterable sequence of the attributes that make up
+	// the identity data.
+	//
+	// The first result of each item is the unique attribute name. Use
+	// [maps.Collect] to produce a map from attribute name to definition.
+	Attributes() iter.Seq2[string, IdentityAttribute]
+
+	// This interface cannot be implemented outside of this module, because
+	// future versions might extend the interface to include new protocol
+	// features.
+	common.Sealed
+}
+
+// IdentityAttribute describes a single attribute within an [IdentitySchema].
+type IdentityAttribute interface {
+	// Type returns the type constraint that any value assigned to this
+	// attribute must conform to.
+	Type() TypeConstraint
+
+	// ImportUsage returns an enumeration value describing whether this
+	// attribute must be set when using identity data to import an object.
+	ImportUsage() IdentityImportUsage
+
+	// DocDescription returns the provider's human-readable description
+	// of the attribute. The second result describes the intended format for the
+	// the description string.
+	DocDescription() (string, DocStringFormat)
+
+	// This interface cannot be implemented outside of this module, because
+	// future versions might extend the interface to include new protocol
+	// features.
+	common.Sealed
+}
+
+// IdentityImportUsage is an enumeration describing whether a particular
+// identity attribute is needed when importing an object by its identity.
+type IdentityImportUsage int
+
+const (
+	// IdentityImportUsageUnsupported represents that the provider returned an
+	// import usage that this library does not understand.
+	IdentityImportUsageUnsupported IdentityImportUsage = 0
+
+	// IdentityRequiredForImport means that the attribute must be set to a
+	// non-null value in the identity data given when importing an object.
+	IdentityRequiredForImport IdentityImportUsage = 1
+
+	// IdentityOptionalForImport means that the attribute may be omitted when
+	// importing an object, because the provider can determine its value
+	// itself.
+	IdentityOptionalForImport IdentityImportUsage = 2
+)
@@ -0,0 +1,215 @@
+package providertrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogSink receives structured log records parsed from the stderr stream of
+// a plugin child process.
+//
+// Plugins implemented using the HashiCorp Plugin Framework or SDK write
+// their logs to stderr as a stream of JSON objects separated by newline
+// characters, in the format used by the hclog library. A LogSink parses
+// each of those lines into a [LogRecord]. Any line that is not in that
+// format is delivered as a plain-text record instead.
+//
+// Set [Tracer.ChildLog] to use a LogSink.
+type LogSink struct {
+	// Level, if not empty, is the most verbose level of log messages that
+	// the plugin should emit. This is passed to the plugin in the TF_LOG
+	// environment variable when it's launched, unless the plugin's
+	// environment already sets that variable.
+	//
+	// If empty, the plugin uses its default log level, which typically
+	// means that it emits no logs at all.
+	Level LogLevel
+
+	// Record is called for each line the plugin writes to its stderr
+	// stream. Calls are not concurrent for any single plugin, but may
+	// be concurrent when the same sink is used for multiple plugins.
+	Record func(rec *LogRecord)
+}
+
+// Writer returns a new [io.WriteCloser] that parses the data written to it
+// and passes the resulting records to the sink.
+//
+// Each plugin child process must have its own writer, so that the lines
+// written by different processes cannot be interleaved. A final line that
+// is not terminated by a newline character is delivered only once the
+// writer is closed.
+//
+// Lines longer than 64KiB are truncated, as described for
+// [LogRecord.Truncated], so that a plugin writing a large amount of data
+// without any newline characters cannot cause unbounded buffering.
+func (s *LogSink) Writer() io.WriteCloser {
+	return &logWriter{sink: s}
+}
+
+// LogLevel is the level of a log message from a plugin.
+type LogLevel string
+
+const (
+	LogTrace LogLevel = "trace"
+	LogDebug LogLevel = "debug"
+	LogInfo  LogLevel = "info"
+	LogWarn  LogLevel = "warn"
+	LogError LogLevel = "error"
+)
+
+// LogRecord is a single log record from a plugin.
+type LogRecord struct {
+	// Level is the level of the message, or an empty string for a
+	// plain-text record.
+	Level LogLevel
+
+	// Timestamp is the time when the message was logged, as reported by the
+	// plugin, or the zero time for a plain-text record.
+	Timestamp time.Time
+
+	// Message is the log message itself. For a plain-text record this is
+	// the entire line, without its trailing newline.
+	Message string
+
+	// Module is the name of the logger that produced the message, which for
+	// providers typically identifies the provider and, optionally, a
+	// subsystem within it. Empty for a plain-text record.
+	Module string
+
+	// Fields are any additional key/value pairs included in the message,
+	// decoded as for [json.Unmarshal] into an interface value. Nil for
+	// a plain-text record.
+	Fields map[string]any
+
+	// Truncated is true for a plain-text record containing only the start
+	// of a line that was too long to buffer in full. The remainder of the
+	// line is discarded.
+	Truncated bool
+}
+
+// maxLogLineLength is the maximum length of a single line from a plugin's
+// stderr stream that a [LogSink] will buffer. Longer lines are truncated to
+// this length and delivered as plain-text records.
+const maxLogLineLength = 64 * 1024
+
+// logWriter is the [io.WriteCloser] implementation returned by
+// [LogSink.Writer].
+type logWriter struct {
+	sink *LogSink
+
+	mu  sync.Mutex
+	buf []byte // incomplete line carried over from an earlier write
+
+	// discarding is set after truncating a line that was too long, until
+	// the end of that line.
+	discarding bool
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	for len(p) != 0 {
+		chunk := p
+		eol := bytes.IndexByte(p, '\n')
+		if eol >= 0 {
+			chunk, p = p[:eol], p[eol+1:]
+		} else {
+			p = nil
+		}
+
+		if !w.discarding {
+			// We only need to keep one byte more than the limit to know
+			// that the line is too long.
+			if room := maxLogLineLength + 1 - len(w.buf); len(chunk) > room {
+				chunk = chunk[:room]
+			}
+			w.buf = append(w.buf, chunk...)
+			if len(w.buf) > maxLogLineLength {
+				w.emitTruncated(w.buf[:maxLogLineLength])
+				w.buf = w.buf[:0]
+				w.discarding = true
+			}
+		}
+		if eol >= 0 {
+			if !w.discarding {
+				w.emit(w.buf)
+			}
+			w.buf = w.buf[:0]
+			w.discarding = false
+		}
+	}
+	return n, nil
+}
+
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) != 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *logWriter) emit(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(line) == 0 || w.sink.Record == nil {
+		return
+	}
+	w.sink.Record(parseLogLine(line))
+}
+
+func (w *logWriter) emitTruncated(line []byte) {
+	if w.sink.Record == nil {
+		return
+	}
+	// A truncated line can't be valid JSON, so there's no point in trying
+	// to parse it.
+	w.sink.Record(&LogRecord{
+		Message:   string(line),
+		Truncated: true,
+	})
+}
+
+// parseLogLine parses a single line of output from a plugin, returning a
+// plain-text record if it is not a JSON object in the hclog format.
+func parseLogLine(line []byte) *LogRecord {
+	var raw map[string]any
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return &LogRecord{Message: string(line)}
+	}
+	level, ok := raw["@level"].(string)
+	if !ok {
+		// Valid JSON, but not a log message from hclog.
+		return &LogRecord{Message: string(line)}
+	}
+
+	ret := &LogRecord{
+		Level:  LogLevel(strings.ToLower(level)),
+		Fields: make(map[string]any),
+	}
+	for k, v := range raw {
+		switch k {
+		case "@level":
+			// Already handled above.
+		case "@message":
+			ret.Message, _ = v.(string)
+		case "@module":
+			ret.Module, _ = v.(string)
+		case "@timestamp":
+			if s, ok := v.(string); ok {
+				ret.Timestamp, _ = time.Parse(time.RFC3339Nano, s)
+			}
+		default:
+			ret.Fields[k] = v
+		}
+	}
+	return ret
+}
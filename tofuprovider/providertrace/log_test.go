@@ -0,0 +1,167 @@
+package providertrace
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := map[string]struct {
+		line string
+		want *LogRecord
+	}{
+		"hclog JSON": {
+			line: `{"@level":"debug","@message":"hello","@module":"provider.terraform-provider-test","@timestamp":"2025-01-02T03:04:05.123456Z","tf_rpc":"ReadResource","count":2}`,
+			want: &LogRecord{
+				Level:     LogDebug,
+				Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC),
+				Message:   "hello",
+				Module:    "provider.terraform-provider-test",
+				Fields: map[string]any{
+					"tf_rpc": "ReadResource",
+					"count":  float64(2),
+				},
+			},
+		},
+		"hclog JSON with uppercase level": {
+			line: `{"@level":"WARN","@message":"careful"}`,
+			want: &LogRecord{
+				Level:   LogWarn,
+				Message: "careful",
+				Fields:  map[string]any{},
+			},
+		},
+		"hclog JSON with invalid timestamp": {
+			line: `{"@level":"info","@message":"hi","@timestamp":"yesterday"}`,
+			want: &LogRecord{
+				Level:   LogInfo,
+				Message: "hi",
+				Fields:  map[string]any{},
+			},
+		},
+		"JSON without level": {
+			line: `{"@message":"hello","foo":"bar"}`,
+			want: &LogRecord{
+				Message: `{"@message":"hello","foo":"bar"}`,
+			},
+		},
+		"JSON array": {
+			line: `["hello"]`,
+			want: &LogRecord{
+				Message: `["hello"]`,
+			},
+		},
+		"plain text": {
+			line: `panic: something went wrong`,
+			want: &LogRecord{
+				Message: `panic: something went wrong`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseLogLine([]byte(test.line))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLogSinkWriter(t *testing.T) {
+	longLine := strings.Repeat("x", maxLogLineLength+100)
+
+	tests := map[string]struct {
+		writes []string
+		want   []LogRecord
+	}{
+		"complete lines": {
+			writes: []string{"first\nsecond\r\n"},
+			want: []LogRecord{
+				{Message: "first"},
+				{Message: "second"},
+			},
+		},
+		"line split across writes": {
+			writes: []string{`{"@level":"info",`, `"@message":"hi"}` + "\n"},
+			want: []LogRecord{
+				{Level: LogInfo, Message: "hi", Fields: map[string]any{}},
+			},
+		},
+		"empty lines": {
+			writes: []string{"\n\nhello\n\n"},
+			want: []LogRecord{
+				{Message: "hello"},
+			},
+		},
+		"partial line flushed on close": {
+			writes: []string{"complete\npartial"},
+			want: []LogRecord{
+				{Message: "complete"},
+				{Message: "partial"},
+			},
+		},
+		"long line": {
+			writes: []string{longLine + "\nafter\n"},
+			want: []LogRecord{
+				{Message: longLine[:maxLogLineLength], Truncated: true},
+				{Message: "after"},
+			},
+		},
+		"long line across writes": {
+			writes: []string{longLine[:100], longLine[100:], longLine, "\nafter\n"},
+			want: []LogRecord{
+				{Message: longLine[:maxLogLineLength], Truncated: true},
+				{Message: "after"},
+			},
+		},
+		"long line without newline": {
+			writes: []string{longLine},
+			want: []LogRecord{
+				{Message: longLine[:maxLogLineLength], Truncated: true},
+			},
+		},
+		"line of exactly the maximum length": {
+			writes: []string{longLine[:maxLogLineLength] + "\n"},
+			want: []LogRecord{
+				{Message: longLine[:maxLogLineLength]},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []LogRecord
+			sink := &LogSink{
+				Record: func(rec *LogRecord) {
+					got = append(got, *rec)
+				},
+			}
+			w := sink.Writer()
+			for _, s := range test.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatalf("write failed: %s", err)
+				}
+				if n != len(s) {
+					t.Fatalf("wrote %d bytes; want %d", n, len(s))
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("close failed: %s", err)
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("wrong number of records %d; want %d\n%#v", len(got), len(test.want), got)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], test.want[i]) {
+					t.Errorf("wrong record %d\ngot:  %#v\nwant: %#v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
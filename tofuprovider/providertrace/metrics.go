@@ -0,0 +1,81 @@
+package providertrace
+
+// Metrics receives measurements about the requests made to plugins, for
+// aggregation into whatever metrics system the caller prefers.
+//
+// Each measurement has one of the metric names declared as constants in
+// this package, such as [MetricOperations], along with [MetricLabels]
+// describing which requests it relates to. Adapters for specific metrics
+// backends can map those to the backend's own concepts.
+//
+// Set [Tracer.Metrics] to use a Metrics.
+type Metrics struct {
+	// If non-nil, Counter is called to add delta to the counter with the
+	// given name and labels.
+	Counter func(name string, labels MetricLabels, delta float64)
+
+	// If non-nil, Histogram is called to record an observation of value in
+	// the histogram with the given name and labels.
+	Histogram func(name string, labels MetricLabels, value float64)
+}
+
+const (
+	// MetricOperations is a counter of the requests made to plugins.
+	MetricOperations = "provider_operations_total"
+
+	// MetricOperationDuration is a histogram of the time taken by requests
+	// made to plugins, in seconds.
+	MetricOperationDuration = "provider_operation_duration_seconds"
+)
+
+// MetricLabels describes the requests that a measurement given to
+// [Metrics] relates to.
+type MetricLabels struct {
+	// ProtocolVersion is the protocol major version used for the request.
+	ProtocolVersion int
+
+	// Operation is the name of the tofuprovider.Provider or
+	// tofuprovider.Provisioner method that made the request.
+	Operation string
+
+	// ResourceType is the name of the resource type that the request relates
+	// to, or an empty string if the operation does not relate to a single
+	// resource type.
+	//
+	// Resource type names are chosen by providers, so callers that are
+	// concerned about the cardinality of their metrics might prefer to
+	// discard this label.
+	ResourceType string
+
+	// Outcome summarizes the result of the request.
+	Outcome Outcome
+}
+
+// Outcome summarizes the result of a request made to a plugin.
+type Outcome string
+
+const (
+	// OutcomeOK means that the request succeeded with no error diagnostics.
+	OutcomeOK Outcome = "ok"
+
+	// OutcomeDiagnosticsError means that the plugin returned a response
+	// including at least one error diagnostic.
+	OutcomeDiagnosticsError Outcome = "diagnostics_error"
+
+	// OutcomeTransportError means that the request failed without the
+	// plugin returning a response, such as when the plugin crashed or
+	// the request timed out.
+	OutcomeTransportError Outcome = "transport_error"
+)
+
+// Outcome returns the [Outcome] that summarizes the result.
+func (r *OperationResult) Outcome() Outcome {
+	switch {
+	case r.Err != nil:
+		return OutcomeTransportError
+	case r.ErrorDiagnostics != 0:
+		return OutcomeDiagnosticsError
+	default:
+		return OutcomeOK
+	}
+}
@@ -0,0 +1,62 @@
+package providertrace
+
+import (
+	"time"
+
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// PluginStartInfo describes a plugin child process that is about to be
+// launched, for [Tracer.PluginStart].
+type PluginStartInfo struct {
+	// Executable and Args are the command line used to launch the plugin.
+	Executable string
+	Args       []string
+}
+
+// HandshakeInfo describes a plugin that has completed its handshake, for
+// [Tracer.HandshakeComplete].
+type HandshakeInfo struct {
+	// ProtocolVersion is the protocol major version that was negotiated
+	// during the handshake.
+	ProtocolVersion int
+
+	// Pid is the process ID of the plugin's child process.
+	Pid int
+}
+
+// OperationInfo describes a request made to a plugin, for
+// [Tracer.OperationStart] and [Tracer.OperationEnd].
+type OperationInfo struct {
+	// Operation is the name of the tofuprovider.Provider or
+	// tofuprovider.Provisioner method that made the request.
+	Operation string
+
+	// ResourceType is the name of the resource type that the request relates
+	// to, or an empty string if the operation does not relate to a single
+	// resource type.
+	ResourceType string
+
+	// ProtocolVersion is the protocol major version used for the request.
+	ProtocolVersion int
+}
+
+// OperationResult describes the outcome of a request made to a plugin, for
+// [Tracer.OperationEnd].
+type OperationResult struct {
+	// Duration is how long the request took to complete.
+	Duration time.Duration
+
+	// StatusCode is the gRPC status code of the response, which is
+	// [grpcCodes.OK] if the request succeeded at the transport level, even
+	// if the response contains error diagnostics.
+	StatusCode grpcCodes.Code
+
+	// Err is the error returned by the request, or nil if it succeeded at
+	// the transport level.
+	Err error
+
+	// ErrorDiagnostics and WarningDiagnostics are the number of error and
+	// warning diagnostics in the response, respectively.
+	ErrorDiagnostics, WarningDiagnostics int
+}
@@ -11,7 +11,13 @@ import (
 //
 // Pass a Tracer to tofuprovider.Start by first wrapping it in a
 // [context.Context] using [ContextWithTracer] and then passing that context
-// (or a child of it with the same values) to tofuprovider.Start.
+// (or a child of it with the same values) to tofuprovider.Start. The hooks
+// related to individual operations use the tracer from the context passed
+// to each operation, and so callers that want to observe all operations
+// should pass a context with the same tracer to each call.
+//
+// All of the hooks are optional, and may be called concurrently from
+// multiple goroutines.
 type Tracer struct {
 	// If non-nil, ChildStderr is used as the stderr stream for the plugin
 	// child process. If nil then any data the child process writes to stderr
@@ -23,8 +29,61 @@ type Tracer struct {
 	// JSON objects separated by newline characters where each object
 	// represents a log message that might be useful for debugging the
 	// provider's behavior, and so callers may wish to expose that information
-	// somehow.
+	// somehow. Use ChildLog to receive those messages already parsed.
 	ChildStderr io.Writer
+
+	// If non-nil, ChildLog receives structured log records parsed from the
+	// stderr stream of the plugin child process. This is in addition to
+	// ChildStderr, and so callers can set either or both.
+	ChildLog *LogSink
+
+	// If non-nil, PluginStart is called just before launching a plugin
+	// child process. The given context is the one passed to the function
+	// launching the plugin.
+	PluginStart func(ctx context.Context, info *PluginStartInfo)
+
+	// If non-nil, HandshakeComplete is called once a plugin child process
+	// has completed its handshake and is ready to accept requests. The
+	// given context is the one passed to the function launching the plugin.
+	HandshakeComplete func(ctx context.Context, info *HandshakeInfo)
+
+	// If non-nil, OperationStart is called at the start of each request
+	// made to a plugin, with the context passed by the caller of the method
+	// making the request.
+	//
+	// The returned context is used for the remainder of the request and is
+	// passed to OperationEnd, so that a tracer can, for example, start a
+	// tracing span here and end it in OperationEnd. Return ctx unchanged if
+	// there's no need to add anything to it.
+	OperationStart func(ctx context.Context, info *OperationInfo) context.Context
+
+	// If non-nil, OperationEnd is called at the end of each request made to
+	// a plugin that previously caused a call to OperationStart, with the
+	// context that OperationStart returned.
+	//
+	// A streaming request, such as a provisioner's ProvisionResource, ends
+	// once the caller has read the whole stream or reading it has failed.
+	// OperationEnd is not called for a stream that the caller abandons
+	// before then.
+	OperationEnd func(ctx context.Context, info *OperationInfo, result *OperationResult)
+
+	// If non-nil, PropagationHeaders is called for each request made to a
+	// plugin to obtain headers that should be sent along with the request
+	// as gRPC metadata, so that a plugin can continue a distributed trace
+	// started by the caller.
+	//
+	// The given context is the one returned by OperationStart, if set, or
+	// otherwise the one passed by the caller of the method making the
+	// request. For W3C Trace Context propagation, the result would typically
+	// include a "traceparent" header and, optionally, a "tracestate" header.
+	//
+	// Header names are case-insensitive and are sent in lowercase, as
+	// required by gRPC.
+	PropagationHeaders func(ctx context.Context) map[string]string
+
+	// If non-nil, Metrics receives measurements of the latency and outcome
+	// of each request made to a plugin.
+	Metrics *Metrics
 }
 
 var defaultTracer = &Tracer{}
@@ -0,0 +1,55 @@
+package tofuprovider
+
+import (
+	"context"
+	"iter"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/provisionerops"
+)
+
+// Provisioner represents operations on a legacy provisioner plugin that cause
+// requests to be sent to the running plugin.
+//
+// Provisioner plugins are a legacy mechanism that predates provider-defined
+// resource types for running side-effects. OpenTofu now includes all of its
+// supported provisioners as built-in functionality, so this is only needed
+// by callers that must support third-party provisioner plugins.
+type Provisioner interface {
+	// GetSchema requests the schema for the provisioner's configuration.
+	GetSchema(ctx context.Context, req *provisionerops.GetSchemaRequest) (provisionerops.GetSchemaResponse, error)
+
+	// ValidateConfig tests whether a given provisioner configuration object
+	// is acceptable per the provisioner's internally-implemented validation
+	// rules.
+	//
+	// This method should be called before calling [ProvisionResource] with
+	// the same configuration.
+	ValidateConfig(ctx context.Context, req *provisionerops.ValidateConfigRequest) (provisionerops.ValidateConfigResponse, error)
+
+	// ProvisionResource asks the provisioner to run its provisioning action,
+	// returning an iterable sequence of the events the provisioner reports
+	// while it's running.
+	//
+	// The request is sent only once the caller begins iterating over the
+	// result, and the sequence ends once the provisioner has finished. If
+	// the request fails then the sequence ends with an item whose error is
+	// non-nil. If the caller stops iterating early then the request is
+	// cancelled.
+	//
+	// Provisioning was successful only if the sequence ends without an error
+	// and none of the events include error diagnostics.
+	ProvisionResource(ctx context.Context, req *provisionerops.ProvisionResourceRequest) iter.Seq2[provisionerops.ProvisionResourceEvent, error]
+
+	// GracefulStop asks the provisioner to gracefully abort any active
+	// calls that are running concurrently, causing them to return
+	// with a cancellation-related error as soon as it's safe to do so.
+	//
+	// The same caveats apply as for [Provider.GracefulStop].
+	GracefulStop(ctx context.Context) error
+
+	// This interface cannot be implemented outside of this module, because
+	// future versions might extend the interface to include new protocol
+	// features.
+	common.Sealed
+}
@@ -0,0 +1,106 @@
+package tofuprovider
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"go.rpcplugin.org/rpcplugin"
+	"google.golang.org/grpc"
+
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/internal/pluginclient"
+	"github.com/opentofu/provider-client/tofuprovider/internal/tf5"
+	"github.com/opentofu/provider-client/tofuprovider/providertrace"
+)
+
+// GRPCPluginProvisioner represents a running provisioner plugin that was
+// started by [StartGRPCProvisioner].
+type GRPCPluginProvisioner interface {
+	// GRPCPluginProvisioner is a subtype of [Provisioner], which represents
+	// the methods supported by all provisioners.
+	//
+	// The other methods of [GRPCPluginProvisioner] below interact with the
+	// child process that the provisioner plugin runs inside, handled locally
+	// inside this library rather than remotely in the plugin.
+	Provisioner
+
+	// ProtocolMajorVersion returns the major version number of the wire
+	// protocol that was negotiated during startup.
+	//
+	// Provisioner plugins are currently supported only for protocol
+	// version 5.
+	ProtocolMajorVersion() int
+
+	// ClientProxy returns the underlying gRPC client proxy object that this
+	// provisioner is using to make the lower-level protocol requests.
+	//
+	// For protocol version 5 the result implements
+	// [tfplugin5.ProvisionerClient]. Refer to
+	// [GRPCPluginProvider.ClientProxy] for some caveats about using this.
+	ClientProxy() any
+
+	// Close terminates the child process representing the provisioner.
+	//
+	// Close behaves in the same way as [GRPCPluginProvider.Close], including
+	// causing any requests made after calling Close to fail with
+	// [ErrProviderClosed].
+	Close() error
+
+	// This interface cannot be implemented outside of this module, because
+	// future versions might extend the interface to include new protocol
+	// features.
+	common.Sealed
+}
+
+// StartGRPCProvisioner executes the given command line, expecting it to
+// behave as a "gRPC-style" provisioner plugin, and returns a
+// [GRPCPluginProvisioner] object representing it.
+//
+// This is the provisioner equivalent of [StartGRPCPlugin], and the same
+// expectations apply about closing the returned object once it's no longer
+// needed. Provisioner plugins are supported only for protocol major
+// version 5.
+func StartGRPCProvisioner(ctx context.Context, exe string, args ...string) (GRPCPluginProvisioner, error) {
+	tracer := providertrace.TracerFromContext(ctx)
+
+	cmd := exec.Command(exe, args...)
+	setChildLogLevel(cmd, tracer)
+	tracePluginStart(ctx, tracer, cmd)
+	stderr := newStderrTail(tracer)
+	rpcPlugin, err := rpcplugin.New(ctx, &rpcplugin.ClientConfig{
+		Handshake: grpcPluginHandshake,
+		Cmd:       cmd,
+		Stderr:    stderr,
+		ProtoVersions: map[int]rpcplugin.ClientVersion{
+			5: grpcConnClientVersion{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch provisioner plugin: %s", err)
+	}
+	plugin := pluginclient.NewChildProcess(rpcPlugin, cmd.Process, stderr)
+
+	protoVersion, clientProxy, err := rpcPlugin.Client(ctx)
+	if err != nil {
+		plugin.Close()
+		return nil, fmt.Errorf("failed to create plugin client: %s", err)
+	}
+	traceHandshakeComplete(ctx, tracer, protoVersion, cmd)
+
+	switch protoVersion {
+	case 5:
+		// These extra steps are to avoid returning a "typed nil" if
+		// NewProvisioner returns (*tf5.Provisioner)(nil).
+		var ret GRPCPluginProvisioner
+		impl, err := tf5.NewProvisioner(ctx, plugin, clientProxy.(*grpc.ClientConn))
+		if impl != nil {
+			ret = impl
+		}
+		return ret, err
+	default:
+		// Should not be possible to get here because the above cases cover
+		// all of the versions we listed in ProtoVersions; rpcplugin bug?
+		panic(fmt.Sprintf("unsupported protocol version %d", protoVersion))
+	}
+}
@@ -0,0 +1,8 @@
+// Package provisionerops contains the request and response types for the
+// operations of a legacy provisioner plugin, as exposed by
+// tofuprovider.Provisioner.
+//
+// Provisioner plugins share many of their data types with provider plugins,
+// and so this package reuses types from packages providerops and
+// providerschema where possible.
+package provisionerops
@@ -0,0 +1,26 @@
+package provisionerops
+
+import (
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+type GetSchemaRequest struct {
+	// There are currently no arguments in a provisioner schema request.
+}
+
+type GetSchemaResponse interface {
+	// Diagnostics are any diagnostics included in the provisioner's response.
+	//
+	// If the result's [providerops.Diagnostics.HasErrors] method returns true
+	// then the results of all other methods are unspecified and meaningless.
+	Diagnostics() providerops.Diagnostics
+
+	// ProvisionerConfigSchema returns the schema for the provisioner's
+	// configuration, as used with the ValidateConfig and ProvisionResource
+	// methods.
+	ProvisionerConfigSchema() providerschema.Schema
+
+	common.Sealed
+}
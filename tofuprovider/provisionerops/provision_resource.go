@@ -0,0 +1,51 @@
+package provisionerops
+
+import (
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+type ProvisionResourceRequest struct {
+	// Config is a dynamic value representation of the provisioner
+	// configuration, which should previously have been validated using
+	// the ValidateConfig operation.
+	//
+	// A value must be provided and its serialization type must be the implied
+	// type of the schema given by this provisioner's
+	// [GetSchemaResponse.ProvisionerConfigSchema] method.
+	Config providerschema.DynamicValueIn
+
+	// Connection is a dynamic value describing how the provisioner should
+	// connect to the remote object it's provisioning, such as the settings
+	// for an SSH connection.
+	//
+	// The protocol does not define a schema for this value. OpenTofu sends
+	// the object described by its "connection" block, whose schema is
+	// defined by OpenTofu itself rather than by the provisioner. Leave this
+	// set to [providerschema.NoDynamicValue] if the provisioner does not
+	// need connection information.
+	Connection providerschema.DynamicValueIn
+}
+
+// ProvisionResourceEvent is a single item from the stream of results
+// produced by a ProvisionResource operation.
+//
+// Each event typically contains either a line of output or some diagnostics,
+// but the protocol allows both to be present in the same event.
+type ProvisionResourceEvent interface {
+	// Output returns some human-oriented output text from the provisioner,
+	// or an empty string if this event has no output.
+	//
+	// Provisioners typically report output one line at a time, without
+	// including the trailing newline character.
+	Output() string
+
+	// Diagnostics are any diagnostics included in this event.
+	//
+	// If the result's [providerops.Diagnostics.HasErrors] method returns
+	// true then the provisioning operation has failed.
+	Diagnostics() providerops.Diagnostics
+
+	common.Sealed
+}
@@ -0,0 +1,29 @@
+package provisionerops
+
+import (
+	"github.com/opentofu/provider-client/tofuprovider/internal/common"
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+type ValidateConfigRequest struct {
+	// Config is a dynamic value representation of the object value
+	// representing the provisioner configuration.
+	//
+	// A value must be provided and its serialization type must be the implied
+	// type of the schema given by this provisioner's
+	// [GetSchemaResponse.ProvisionerConfigSchema] method.
+	Config providerschema.DynamicValueIn
+}
+
+type ValidateConfigResponse interface {
+	// Diagnostics describe any problems the provisioner reported with the
+	// provided configuration.
+	//
+	// If this includes any error diagnostics then the configuration object
+	// is somehow invalid and so passing it to ProvisionResource causes
+	// unspecified behavior.
+	Diagnostics() providerops.Diagnostics
+
+	common.Sealed
+}
@@ -0,0 +1,256 @@
+package tofuprovider
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"sync"
+
+	"github.com/opentofu/provider-client/tofuprovider/providerops"
+	"github.com/opentofu/provider-client/tofuprovider/providerschema"
+)
+
+// SchemaCache wraps a [Provider] to delay fetching its full schema until a
+// caller actually needs schema information.
+//
+// If the provider supports the GetMetadata operation and reports that calling
+// GetProviderSchema is optional then a SchemaCache can answer questions about
+// which resource types and functions are available without fetching the full
+// schema at all. Otherwise the full schema is fetched immediately by
+// [NewSchemaCache], because the provider protocol requires that.
+//
+// The provider protocol has no way to fetch the schema of a single type, so
+// the first request for the schema of any type still fetches and decodes the
+// full schema, even if GetProviderSchema is optional. Deferring that request
+// until it's needed, or avoiding it entirely for callers that only need the
+// type names, is the only saving. The first lookup in each category of schema
+// then indexes that category by name, so that later lookups in the same
+// category, including those for names that don't exist, don't search the
+// whole schema again.
+//
+// A SchemaCache is safe for concurrent use.
+type SchemaCache struct {
+	provider Provider
+
+	// metadata is the response from GetMetadata, or nil if the provider does
+	// not support that operation or returned error diagnostics for it.
+	metadata providerops.GetMetadataResponse
+
+	mu sync.Mutex
+
+	// schema is the response from GetProviderSchema, or nil if we've not
+	// yet needed to fetch the full schema.
+	schema providerops.GetProviderSchemaResponse
+
+	// These index each category of schema by name, and are each nil until
+	// the first lookup in that category.
+	managed   map[string]providerschema.Schema
+	data      map[string]providerschema.Schema
+	ephemeral map[string]providerschema.Schema
+	functions map[string]providerschema.FunctionSignature
+}
+
+// NewSchemaCache prepares a [SchemaCache] for the given provider, which should
+// not yet have had any other methods called on it.
+//
+// This makes a GetMetadata request to the provider and then, unless the
+// provider reports that it's okay to skip it, a GetProviderSchema request too.
+// Therefore it should be called before any other use of the provider, in
+// place of calling GetProviderSchema directly.
+//
+// If the provider doesn't support GetMetadata, or returns error diagnostics
+// in response to it, then NewSchemaCache fetches the full schema instead.
+func NewSchemaCache(ctx context.Context, provider Provider) (*SchemaCache, error) {
+	ret := &SchemaCache{
+		provider: provider,
+	}
+
+	metaResp, err := provider.GetMetadata(ctx, &providerops.GetMetadataRequest{})
+	switch {
+	case providerops.IsUnimplementedErr(err):
+		// The provider predates GetMetadata, so we'll need to fetch the
+		// full schema immediately below.
+	case err != nil:
+		return nil, err
+	case metaResp.Diagnostics().HasErrors():
+		// The full schema is a complete substitute for the metadata, so
+		// we'll try that instead. If the provider is generally broken then
+		// GetProviderSchema will presumably fail too.
+	default:
+		ret.metadata = metaResp
+	}
+
+	if ret.metadata == nil || !ret.metadata.ServerCapabilities().GetProviderSchemaIsOptional() {
+		if _, err := ret.ProviderSchema(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// ServerCapabilities returns the capabilities the provider reported, either
+// in its GetMetadata response or its GetProviderSchema response.
+func (c *SchemaCache) ServerCapabilities() providerops.ServerCapabilities {
+	if c.metadata != nil {
+		return c.metadata.ServerCapabilities()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// If we don't have metadata then NewSchemaCache must have loaded the
+	// full schema already.
+	return c.schema.ServerCapabilities()
+}
+
+// ManagedResourceTypeNames returns an iterable sequence of the names of all
+// of the managed resource types supported by the provider.
+func (c *SchemaCache) ManagedResourceTypeNames() iter.Seq[string] {
+	if c.metadata != nil {
+		return c.metadata.ManagedResourceTypeNames()
+	}
+	return maps.Keys(maps.Collect(c.loadedSchema().ManagedResourceTypeSchemas()))
+}
+
+// DataResourceTypeNames returns an iterable sequence of the names of all
+// of the data resource types supported by the provider.
+func (c *SchemaCache) DataResourceTypeNames() iter.Seq[string] {
+	if c.metadata != nil {
+		return c.metadata.DataResourceTypeNames()
+	}
+	return maps.Keys(maps.Collect(c.loadedSchema().DataResourceTypeSchemas()))
+}
+
+// EphemeralResourceTypeNames returns an iterable sequence of the names of all
+// of the ephemeral resource types supported by the provider.
+func (c *SchemaCache) EphemeralResourceTypeNames() iter.Seq[string] {
+	if c.metadata != nil {
+		return c.metadata.EphemeralResourceTypeNames()
+	}
+	return maps.Keys(maps.Collect(c.loadedSchema().EphemeralResourceTypeSchemas()))
+}
+
+// FunctionNames returns an iterable sequence of the names of all of the
+// functions supported by the provider.
+func (c *SchemaCache) FunctionNames() iter.Seq[string] {
+	if c.metadata != nil {
+		return c.metadata.FunctionNames()
+	}
+	return maps.Keys(maps.Collect(c.loadedSchema().FunctionSignatures()))
+}
+
+// ProviderSchema returns the provider's full schema, fetching it first if
+// it has not been fetched already.
+//
+// Returns an error if the request fails or if the provider returns error
+// diagnostics.
+func (c *SchemaCache) ProviderSchema(ctx context.Context) (providerschema.ProviderSchema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.providerSchemaLocked(ctx)
+}
+
+// ProviderConfigSchema returns the schema for the provider's own
+// configuration, fetching the full schema first if necessary.
+func (c *SchemaCache) ProviderConfigSchema(ctx context.Context) (providerschema.Schema, error) {
+	schema, err := c.ProviderSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return schema.ProviderConfigSchema(), nil
+}
+
+// ManagedResourceTypeSchema returns the schema for the managed resource type
+// of the given name, fetching the full schema first if necessary.
+//
+// Returns a nil schema without an error if the provider does not support
+// a managed resource type of the given name.
+func (c *SchemaCache) ManagedResourceTypeSchema(ctx context.Context, typeName string) (providerschema.Schema, error) {
+	return cachedLookup(ctx, c, &c.managed, typeName, providerschema.ProviderSchema.ManagedResourceTypeSchemas)
+}
+
+// DataResourceTypeSchema returns the schema for the data resource type
+// of the given name, fetching the full schema first if necessary.
+//
+// Returns a nil schema without an error if the provider does not support
+// a data resource type of the given name.
+func (c *SchemaCache) DataResourceTypeSchema(ctx context.Context, typeName string) (providerschema.Schema, error) {
+	return cachedLookup(ctx, c, &c.data, typeName, providerschema.ProviderSchema.DataResourceTypeSchemas)
+}
+
+// EphemeralResourceTypeSchema returns the schema for the ephemeral resource
+// type of the given name, fetching the full schema first if necessary.
+//
+// Returns a nil schema without an error if the provider does not support
+// an ephemeral resource type of the given name.
+func (c *SchemaCache) EphemeralResourceTypeSchema(ctx context.Context, typeName string) (providerschema.Schema, error) {
+	return cachedLookup(ctx, c, &c.ephemeral, typeName, providerschema.ProviderSchema.EphemeralResourceTypeSchemas)
+}
+
+// FunctionSignature returns the signature of the function of the given name,
+// fetching the full schema first if necessary.
+//
+// Returns a nil signature without an error if the provider does not support
+// a function of the given name.
+func (c *SchemaCache) FunctionSignature(ctx context.Context, name string) (providerschema.FunctionSignature, error) {
+	return cachedLookup(ctx, c, &c.functions, name, providerschema.ProviderSchema.FunctionSignatures)
+}
+
+// cachedLookup is the common implementation of the per-type lookup methods
+// of [SchemaCache], which looks up name in the index that index points to,
+// first building that index from the sequence returned by getAll if this is
+// the first lookup in its category.
+func cachedLookup[T any](ctx context.Context, c *SchemaCache, index *map[string]T, name string, getAll func(providerschema.ProviderSchema) iter.Seq2[string, T]) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if *index == nil {
+		schema, err := c.providerSchemaLocked(ctx)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		*index = maps.Collect(getAll(schema))
+	}
+	// If there's no element with the given name then this returns the
+	// zero value of T, which is nil for all of the types we use this with.
+	return (*index)[name], nil
+}
+
+func (c *SchemaCache) providerSchemaLocked(ctx context.Context) (providerschema.ProviderSchema, error) {
+	if c.schema != nil {
+		return c.schema.ProviderSchema(), nil
+	}
+	resp, err := c.provider.GetProviderSchema(ctx, &providerops.GetProviderSchemaRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Diagnostics().HasErrors() {
+		return nil, diagnosticsError("provider schema", resp.Diagnostics())
+	}
+	c.schema = resp
+	return resp.ProviderSchema(), nil
+}
+
+// loadedSchema returns the full provider schema, which must already have
+// been loaded by an earlier call.
+func (c *SchemaCache) loadedSchema() providerschema.ProviderSchema {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.schema.ProviderSchema()
+}
+
+// diagnosticsError returns an error describing the first error diagnostic
+// in the given diagnostics, for situations where a helper function needs to
+// return an error to the caller rather than the diagnostics themselves.
+func diagnosticsError(what string, diags providerops.Diagnostics) error {
+	for diag := range diags.All() {
+		if diag.Severity() != providerops.DiagnosticError {
+			continue
+		}
+		if detail := diag.Detail(); detail != "" {
+			return fmt.Errorf("failed to fetch %s: %s: %s", what, diag.Summary(), detail)
+		}
+		return fmt.Errorf("failed to fetch %s: %s", what, diag.Summary())
+	}
+	return fmt.Errorf("failed to fetch %s", what)
+}
@@ -0,0 +1,181 @@
+package tofuprovider
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/opentofu/provider-client/tofuprovider/grpc/tfplugin6"
+)
+
+func TestSchemaCacheLazy(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeProviderConn{
+		responses: map[string]proto.Message{
+			"/tfplugin6.Provider/GetMetadata": &tfplugin6.GetMetadata_Response{
+				ServerCapabilities: &tfplugin6.ServerCapabilities{
+					GetProviderSchemaOptional: true,
+				},
+				Resources: []*tfplugin6.GetMetadata_ResourceMetadata{
+					{TypeName: "test_a"},
+					{TypeName: "test_b"},
+				},
+			},
+			"/tfplugin6.Provider/GetProviderSchema": testProviderSchemaResponse(),
+		},
+	}
+	provider, err := NewGRPCProvider(ctx, conn, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewSchemaCache(ctx, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conn.calls["/tfplugin6.Provider/GetProviderSchema"]; got != 0 {
+		t.Fatalf("NewSchemaCache called GetProviderSchema %d times; want 0", got)
+	}
+	gotNames := slices.Sorted(cache.ManagedResourceTypeNames())
+	if want := []string{"test_a", "test_b"}; !slices.Equal(gotNames, want) {
+		t.Errorf("wrong managed resource type names %q; want %q", gotNames, want)
+	}
+	if got := conn.calls["/tfplugin6.Provider/GetProviderSchema"]; got != 0 {
+		t.Fatalf("listing type names called GetProviderSchema %d times; want 0", got)
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		for range 2 {
+			schema, err := cache.ManagedResourceTypeSchema(ctx, "test_a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema == nil {
+				t.Fatal("no schema for test_a")
+			}
+		}
+	})
+	t.Run("miss", func(t *testing.T) {
+		for range 2 {
+			schema, err := cache.ManagedResourceTypeSchema(ctx, "test_missing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema != nil {
+				t.Fatalf("unexpected schema for test_missing: %#v", schema)
+			}
+		}
+		sig, err := cache.FunctionSignature(ctx, "missing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig != nil {
+			t.Fatalf("unexpected signature for missing function: %#v", sig)
+		}
+	})
+	if got := conn.calls["/tfplugin6.Provider/GetProviderSchema"]; got != 1 {
+		t.Errorf("GetProviderSchema called %d times; want 1", got)
+	}
+}
+
+func TestSchemaCacheMetadataFallback(t *testing.T) {
+	tests := map[string]proto.Message{
+		// The fake connection responds Unimplemented to methods that have
+		// no response.
+		"unimplemented": nil,
+		"error diagnostics": &tfplugin6.GetMetadata_Response{
+			Diagnostics: []*tfplugin6.Diagnostic{
+				{
+					Severity: tfplugin6.Diagnostic_ERROR,
+					Summary:  "metadata unavailable",
+				},
+			},
+		},
+	}
+	for name, metadataResp := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			conn := &fakeProviderConn{
+				responses: map[string]proto.Message{
+					"/tfplugin6.Provider/GetProviderSchema": testProviderSchemaResponse(),
+				},
+			}
+			if metadataResp != nil {
+				conn.responses["/tfplugin6.Provider/GetMetadata"] = metadataResp
+			}
+			provider, err := NewGRPCProvider(ctx, conn, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cache, err := NewSchemaCache(ctx, provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := conn.calls["/tfplugin6.Provider/GetProviderSchema"]; got != 1 {
+				t.Fatalf("NewSchemaCache called GetProviderSchema %d times; want 1", got)
+			}
+			gotNames := slices.Sorted(cache.ManagedResourceTypeNames())
+			if want := []string{"test_a"}; !slices.Equal(gotNames, want) {
+				t.Errorf("wrong managed resource type names %q; want %q", gotNames, want)
+			}
+			if !cache.ServerCapabilities().CanMoveManagedResourceState() {
+				t.Errorf("capabilities did not come from the provider schema")
+			}
+			schema, err := cache.ManagedResourceTypeSchema(ctx, "test_a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema == nil {
+				t.Fatal("no schema for test_a")
+			}
+			if got := conn.calls["/tfplugin6.Provider/GetProviderSchema"]; got != 1 {
+				t.Errorf("GetProviderSchema called %d times; want 1", got)
+			}
+		})
+	}
+}
+
+func testProviderSchemaResponse() *tfplugin6.GetProviderSchema_Response {
+	return &tfplugin6.GetProviderSchema_Response{
+		ServerCapabilities: &tfplugin6.ServerCapabilities{
+			MoveResourceState: true,
+		},
+		ResourceSchemas: map[string]*tfplugin6.Schema{
+			"test_a": {Block: &tfplugin6.Schema_Block{}},
+		},
+	}
+}
+
+// fakeProviderConn is a [grpc.ClientConnInterface] that responds to each
+// request with a fixed response message for its method, and counts the
+// requests for each method.
+type fakeProviderConn struct {
+	// responses are the responses for each full gRPC method name. Requests
+	// for methods not included here fail with Unimplemented.
+	responses map[string]proto.Message
+
+	calls map[string]int
+}
+
+func (c *fakeProviderConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[method]++
+	resp, ok := c.responses[method]
+	if !ok {
+		return grpcStatus.Errorf(grpcCodes.Unimplemented, "unknown method %s", method)
+	}
+	proto.Merge(reply.(proto.Message), resp)
+	return nil
+}
+
+func (c *fakeProviderConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, grpcStatus.Errorf(grpcCodes.Unimplemented, "unknown method %s", method)
+}